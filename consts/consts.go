@@ -3,8 +3,10 @@ package consts
 // http
 const (
 	UrlKey         = "url"
+	MethodKey      = "method"
 	QueryKey       = "query"
 	VariablesKey   = "variables"
+	OperationNameKey = "operationName"
 	ContentTypeKey = "contentType"
 	HeadersKey     = "headers"
 	CookiesKey     = "cookies"
@@ -15,9 +17,96 @@ const (
 	BasicAuthKey   = "basic-auth"
 	BearerAuthKey  = "bearer-token"
 	ApiTokenKey    = "apikey-auth"
+	HmacSignatureKey = "hmac-signature"
 	ApiAddressKey  = "API Address"
 	RequestUrlKey  = "REQUEST_URL"
 
+	TlsCaCertKey         = "TLS_CA_CERT"
+	TlsClientCertKey     = "TLS_CLIENT_CERT"
+	TlsClientKeyKey      = "TLS_CLIENT_KEY"
+	TlsPkcs12Key         = "TLS_PKCS12"
+	TlsPkcs12PasswordKey = "TLS_PKCS12_PASSWORD"
+	TlsServerNameKey     = "TLS_SERVER_NAME"
+	TlsMinVersionKey     = "TLS_MIN_VERSION"
+
+	ProxyUrlKey      = "PROXY_URL"
+	ProxyUsernameKey = "PROXY_USERNAME"
+	ProxyPasswordKey = "PROXY_PASSWORD"
+	NoProxyKey       = "NO_PROXY"
+
+	HmacKeyIdKey           = "KEY_ID"
+	HmacSecretKey          = "SECRET"
+	HmacSecretEncodingKey  = "SECRET_ENCODING"
+	HmacAlgorithmKey       = "ALGORITHM"
+	HmacTemplateKey        = "TEMPLATE"
+	HmacEncodingKey        = "ENCODING"
+	HmacSignatureHeaderKey = "SIGNATURE_HEADER"
+	HmacSignaturePrefixKey = "SIGNATURE_PREFIX"
+	HmacKeyIdHeaderKey     = "KEY_ID_HEADER"
+	HmacTimestampHeaderKey = "TIMESTAMP_HEADER"
+	HmacTimestampFormatKey = "TIMESTAMP_FORMAT"
+	HmacNonceHeaderKey     = "NONCE_HEADER"
+
+	RetryMaxAttemptsKey   = "retryMaxAttempts"
+	RetryBackoffBaseKey   = "retryBackoffBase"
+	RetryBackoffMaxKey    = "retryBackoffMax"
+	RetryJitterKey        = "retryJitter"
+	RetryStatusCodesKey   = "retryStatusCodes"
+	RetryNonIdempotentKey = "retryNonIdempotent"
+
+	OutputFormatKey = "output_format"
+
+	ExtractKey = "extract"
+
+	ConvertResponseKey = "convertResponse"
+
+	DownloadPathKey    = "downloadPath"
+	DownloadMaxSizeKey = "downloadMaxSize"
+
+	MessagesKey       = "messages"
+	MaxMessagesKey    = "maxMessages"
+	ReceiveTimeoutKey = "receiveTimeout"
+	UntilKey          = "until"
+	SubprotocolsKey   = "subprotocols"
+
+	MaxEventsKey     = "maxEvents"
+	IdleTimeoutKey   = "idleTimeout"
+	LastEventIdKey   = "lastEventId"
+	MaxReconnectsKey = "maxReconnects"
+
+	ParamsKey = "params"
+	BatchKey  = "batch"
+
+	WsdlKey           = "wsdl"
+	SoapOperationKey  = "operation"
+	SoapVersionKey    = "soapVersion"
+	WsUsernameKey     = "wsUsername"
+	WsPasswordKey     = "wsPassword"
+	WsPasswordTypeKey = "wsPasswordType"
+
+	SessionIdKey        = "session_id"
+	SessionTtlKey       = "session_ttl"
+	SessionOperationKey = "operation"
+
+	MultipartFieldsKey = "multipartFields"
+
+	MaxRedirectsKey    = "maxRedirects"
+	FollowRedirectsKey = "followRedirects"
+
+	PaginationKey            = "pagination"
+	PaginationItemsPathKey   = "paginationItemsPath"
+	PaginationCursorPathKey  = "paginationCursorPath"
+	PaginationCursorParamKey = "paginationCursorParam"
+	PaginationOffsetParamKey = "paginationOffsetParam"
+	PaginationPageParamKey   = "paginationPageParam"
+	PaginationLimitParamKey  = "paginationLimitParam"
+	PaginationPageSizeKey    = "paginationPageSize"
+	PaginationMaxPagesKey    = "paginationMaxPages"
+	PaginationMaxItemsKey    = "paginationMaxItems"
+
+	PaginationConnectionPathKey = "paginationConnectionPath"
+	PaginationCursorVariableKey = "paginationCursorVariable"
+
 	BasicAuthPrefix = "Basic "
 	BearerAuthPrefix = "Bearer "
 
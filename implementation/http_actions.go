@@ -3,13 +3,24 @@ package implementation
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/blinkops/blink-http/consts"
 	"github.com/blinkops/blink-http/implementation/requests"
 	"github.com/blinkops/blink-http/plugins/types"
 	"github.com/blinkops/blink-sdk/plugin"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// bodylessMethods never carry a request body, so no Content-Type is sent with them
+var bodylessMethods = map[string]bool{
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
 func executeHTTPGetAction(ctx *plugin.ActionContext, request *plugin.ExecuteActionRequest, plugin types.Plugin) ([]byte, error) {
 	return executeCoreHTTPAction(ctx, http.MethodGet, request, plugin)
 }
@@ -30,6 +41,26 @@ func executeHTTPPatchAction(ctx *plugin.ActionContext, request *plugin.ExecuteAc
 	return executeCoreHTTPAction(ctx, http.MethodPatch, request, plugin)
 }
 
+func executeHTTPRequestAction(ctx *plugin.ActionContext, request *plugin.ExecuteActionRequest, plugin types.Plugin) ([]byte, error) {
+	method := strings.ToUpper(strings.TrimSpace(request.Parameters[consts.MethodKey]))
+	if method == "" {
+		return nil, errors.New("no method provided for execution")
+	}
+	if strings.IndexFunc(method, isInvalidMethodRune) >= 0 {
+		return nil, fmt.Errorf("invalid method: %s", method)
+	}
+
+	return executeCoreHTTPAction(ctx, method, request, plugin)
+}
+
+// isInvalidMethodRune reports whether the rune can't be part of a method token (RFC 7230)
+func isInvalidMethodRune(r rune) bool {
+	if r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+		return false
+	}
+	return !strings.ContainsRune("!#$%&'*+-.^_`|~", r)
+}
+
 func executeCoreHTTPAction(ctx *plugin.ActionContext, method string, request *plugin.ExecuteActionRequest, plugin types.Plugin) ([]byte, error) {
 	providedUrl, ok := request.Parameters[consts.UrlKey]
 	if !ok {
@@ -56,10 +87,78 @@ func executeCoreHTTPAction(ctx *plugin.ActionContext, method string, request *pl
 		body = ""
 	}
 
-	headerMap := requests.GetHeaders(contentType, headers)
-	cookieMap := requests.ParseStringToMap(cookies, "=")
+	// the multipart body is built from the fields, along with the boundary in the content type
+	if fields := request.Parameters[consts.MultipartFieldsKey]; strings.TrimSpace(fields) != "" {
+		if body != "" {
+			return nil, errors.New("body and multipart fields can't be provided together")
+		}
+		multipartBody, multipartContentType, err := requests.BuildMultipartBody(fields)
+		if err != nil {
+			return nil, err
+		}
+		body, contentType = string(multipartBody), multipartContentType
+	}
+
+	options, err := getRequestOptions(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	options.ConvertResponse = request.Parameters[consts.ConvertResponseKey]
+	if err = requests.ValidateResponseConversion(options.ConvertResponse); err != nil {
+		return nil, err
+	}
+
+	if options.Download, err = getDownloadOptions(request); err != nil {
+		return nil, err
+	}
+	if options.Download != nil {
+		if bodylessMethods[method] {
+			return nil, fmt.Errorf("%s responses have no body to download", method)
+		}
+		if options.ConvertResponse != "" && options.ConvertResponse != requests.ConvertResponseNone {
+			return nil, errors.New("downloaded responses are written as is and can't be combined with response conversion")
+		}
+	}
 
-	return requests.SendRequest(ctx, plugin, method, providedUrl, request.Timeout, headerMap, cookieMap, []byte(body))
+	pagination, err := requests.ParsePagination(request.Parameters, plugin)
+	if err != nil {
+		return nil, err
+	}
+
+	if bodylessMethods[method] && body != "" {
+		return nil, fmt.Errorf("%s requests can't have a body", method)
+	}
+
+	headerMap, err := requests.GetHeaders(contentType, headers)
+	if err != nil {
+		return nil, err
+	}
+	if bodylessMethods[method] {
+		headerMap.Del("Content-Type")
+	}
+	cookieMap, err := requests.ParseCookies(cookies)
+	if err != nil {
+		return nil, err
+	}
+
+	if pagination != nil {
+		if method != http.MethodGet {
+			return nil, errors.New("pagination is only supported for get requests")
+		}
+		if options.OutputFormat == requests.OutputFormatEnvelope {
+			return nil, errors.New("pagination returns the items of all the pages and can't be combined with the envelope output format")
+		}
+		if options.ConvertResponse != "" && options.ConvertResponse != requests.ConvertResponseNone {
+			return nil, errors.New("pagination reads the items of JSON pages and can't be combined with response conversion")
+		}
+		if options.Download != nil {
+			return nil, errors.New("pagination returns the items of all the pages and can't be combined with a download")
+		}
+		return requests.SendPaginatedRequest(ctx, plugin, providedUrl, request.Timeout, headerMap, cookieMap, options, pagination)
+	}
+
+	return requests.SendRequestWithOptions(ctx, plugin, method, providedUrl, request.Timeout, headerMap, cookieMap, []byte(body), options)
 }
 
 func executeGraphQL(ctx *plugin.ActionContext, request *plugin.ExecuteActionRequest, plugin types.Plugin) ([]byte, error) {
@@ -73,17 +172,309 @@ func executeGraphQL(ctx *plugin.ActionContext, request *plugin.ExecuteActionRequ
 		query = ""
 	}
 
-	variables, ok := request.Parameters[consts.VariablesKey]
+	graphQLRequest, err := requests.NewGraphQLRequest(query, request.Parameters[consts.VariablesKey], request.Parameters[consts.OperationNameKey])
+	if err != nil {
+		return nil, err
+	}
+
+	options, err := getRequestOptions(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	pagination, err := requests.ParseGraphQLPagination(request.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	if pagination != nil {
+		if options.OutputFormat == requests.OutputFormatEnvelope {
+			return nil, errors.New("pagination returns the nodes of all the pages and can't be combined with the envelope output format")
+		}
+		return requests.SendPaginatedGraphQLRequest(ctx, plugin, providedUrl, request.Timeout, nil, graphQLRequest, options, pagination)
+	}
+
+	return requests.SendGraphQLRequest(ctx, plugin, providedUrl, request.Timeout, nil, graphQLRequest, options)
+}
+
+func executeWebSocket(ctx *plugin.ActionContext, request *plugin.ExecuteActionRequest, plugin types.Plugin) ([]byte, error) {
+	providedUrl, ok := request.Parameters[consts.UrlKey]
+	if !ok {
+		return nil, errors.New("no url provided for execution")
+	}
+
+	headers, err := requests.ParseHeaders(request.Parameters[consts.HeadersKey])
+	if err != nil {
+		return nil, err
+	}
+
+	messages, err := requests.ParseWebSocketMessages(request.Parameters[consts.MessagesKey])
+	if err != nil {
+		return nil, err
+	}
+
+	until, err := requests.NewExtractor(request.Parameters[consts.UntilKey])
+	if err != nil {
+		return nil, err
+	}
+
+	options := &requests.WebSocketOptions{
+		Messages:       messages,
+		MaxMessages:    1,
+		ReceiveTimeout: requests.DefaultWebSocketReceiveTimeout,
+		Until:          until,
+	}
+
+	if value := request.Parameters[consts.MaxMessagesKey]; value != "" {
+		if options.MaxMessages, err = strconv.Atoi(value); err != nil || options.MaxMessages < 0 {
+			return nil, fmt.Errorf("invalid %s: %s, must be a non negative integer", consts.MaxMessagesKey, value)
+		}
+	}
+
+	if value := request.Parameters[consts.ReceiveTimeoutKey]; value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 1 {
+			return nil, fmt.Errorf("invalid %s: %s, must be a positive number of seconds", consts.ReceiveTimeoutKey, value)
+		}
+		options.ReceiveTimeout = time.Second * time.Duration(seconds)
+	}
+
+	for _, subprotocol := range strings.Split(request.Parameters[consts.SubprotocolsKey], ",") {
+		if subprotocol = strings.TrimSpace(subprotocol); subprotocol != "" {
+			options.Subprotocols = append(options.Subprotocols, subprotocol)
+		}
+	}
+
+	return requests.SendWebSocketMessages(ctx, plugin, providedUrl, request.Timeout, headers, options)
+}
+
+func executeSse(ctx *plugin.ActionContext, request *plugin.ExecuteActionRequest, plugin types.Plugin) ([]byte, error) {
+	providedUrl, ok := request.Parameters[consts.UrlKey]
+	if !ok {
+		return nil, errors.New("no url provided for execution")
+	}
+
+	headers, err := requests.ParseHeaders(request.Parameters[consts.HeadersKey])
+	if err != nil {
+		return nil, err
+	}
+
+	until, err := requests.NewExtractor(request.Parameters[consts.UntilKey])
+	if err != nil {
+		return nil, err
+	}
+
+	options := &requests.SseOptions{
+		MaxEvents:     10,
+		IdleTimeout:   requests.DefaultSseIdleTimeout,
+		Until:         until,
+		LastEventId:   request.Parameters[consts.LastEventIdKey],
+		MaxReconnects: 3,
+	}
+
+	for key, field := range map[string]*int{
+		consts.MaxEventsKey:     &options.MaxEvents,
+		consts.MaxReconnectsKey: &options.MaxReconnects,
+	} {
+		if value := request.Parameters[key]; value != "" {
+			number, err := strconv.Atoi(value)
+			if err != nil || number < 0 {
+				return nil, fmt.Errorf("invalid %s: %s, must be a non negative integer", key, value)
+			}
+			*field = number
+		}
+	}
+
+	if value := request.Parameters[consts.IdleTimeoutKey]; value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 1 {
+			return nil, fmt.Errorf("invalid %s: %s, must be a positive number of seconds", consts.IdleTimeoutKey, value)
+		}
+		options.IdleTimeout = time.Second * time.Duration(seconds)
+	}
+
+	return requests.ReadServerSentEvents(ctx, plugin, providedUrl, request.Timeout, headers, options)
+}
+
+func executeJsonRpc(ctx *plugin.ActionContext, request *plugin.ExecuteActionRequest, plugin types.Plugin) ([]byte, error) {
+	providedUrl, ok := request.Parameters[consts.UrlKey]
 	if !ok {
-		variables = ""
+		return nil, errors.New("no url provided for execution")
+	}
+
+	headers, err := requests.ParseHeaders(request.Parameters[consts.HeadersKey])
+	if err != nil {
+		return nil, err
 	}
 
-	body, err := json.Marshal(map[string]string{"query": query, "variables": variables})
+	options, err := getRequestOptions(ctx, request)
 	if err != nil {
 		return nil, err
 	}
 
-	headerMap := map[string]string{"Content-Type": "application/json"}
+	if batch := strings.TrimSpace(request.Parameters[consts.BatchKey]); batch != "" {
+		batchRequests, err := requests.ParseJsonRpcBatch(batch)
+		if err != nil {
+			return nil, err
+		}
+		return requests.SendJsonRpcBatch(ctx, plugin, providedUrl, request.Timeout, headers, batchRequests, options)
+	}
+
+	jsonRpcRequest, err := requests.NewJsonRpcRequest(request.Parameters[consts.MethodKey], request.Parameters[consts.ParamsKey], false)
+	if err != nil {
+		return nil, err
+	}
+	return requests.SendJsonRpcRequest(ctx, plugin, providedUrl, request.Timeout, headers, jsonRpcRequest, options)
+}
+
+// executeSoap lists the operations of the WSDL when no operation is provided, otherwise it calls the operation
+func executeSoap(ctx *plugin.ActionContext, request *plugin.ExecuteActionRequest, plugin types.Plugin) ([]byte, error) {
+	wsdl, err := requests.LoadWsdl(ctx, plugin, request.Parameters[consts.WsdlKey], request.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	soapVersion := strings.TrimSpace(request.Parameters[consts.SoapVersionKey])
+	if soapVersion != "" && soapVersion != requests.SoapVersion11 && soapVersion != requests.SoapVersion12 {
+		return nil, fmt.Errorf("invalid %s: %s, must be %s or %s", consts.SoapVersionKey, soapVersion, requests.SoapVersion11, requests.SoapVersion12)
+	}
+
+	operationName := strings.TrimSpace(request.Parameters[consts.SoapOperationKey])
+	if operationName == "" {
+		return json.Marshal(wsdl.Operations)
+	}
+	operation, err := wsdl.GetOperation(operationName, soapVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	headers, err := requests.ParseHeaders(request.Parameters[consts.HeadersKey])
+	if err != nil {
+		return nil, err
+	}
 
-	return requests.SendRequest(ctx, plugin, http.MethodPost, providedUrl, request.Timeout, headerMap, nil, body)
+	options, err := getRequestOptions(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	var security *requests.WsSecurity
+	if username := request.Parameters[consts.WsUsernameKey]; username != "" {
+		security = &requests.WsSecurity{
+			Username:     username,
+			Password:     request.Parameters[consts.WsPasswordKey],
+			PasswordType: request.Parameters[consts.WsPasswordTypeKey],
+		}
+	}
+
+	return requests.SendSoapRequest(ctx, plugin, request.Parameters[consts.UrlKey], request.Timeout, headers, operation, request.Parameters[consts.ParamsKey], security, options)
+}
+
+// getDownloadOptions returns nil when no download path is provided
+func getDownloadOptions(request *plugin.ExecuteActionRequest) (*requests.DownloadOptions, error) {
+	downloadPath := strings.TrimSpace(request.Parameters[consts.DownloadPathKey])
+	if downloadPath == "" {
+		return nil, nil
+	}
+
+	download := &requests.DownloadOptions{Path: downloadPath, MaxSize: requests.DefaultDownloadMaxSize}
+	if value := request.Parameters[consts.DownloadMaxSizeKey]; value != "" {
+		maxSize, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || maxSize < 1 {
+			return nil, fmt.Errorf("invalid %s: %s, must be a positive number of bytes", consts.DownloadMaxSizeKey, value)
+		}
+		download.MaxSize = maxSize
+	}
+	return download, nil
+}
+
+func getRequestOptions(ctx *plugin.ActionContext, request *plugin.ExecuteActionRequest) (*requests.RequestOptions, error) {
+	retryPolicy, err := requests.ParseRetryPolicy(request.Parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	outputFormat := request.Parameters[consts.OutputFormatKey]
+	if err = requests.ValidateOutputFormat(outputFormat); err != nil {
+		return nil, err
+	}
+
+	extractor, err := requests.NewExtractor(request.Parameters[consts.ExtractKey])
+	if err != nil {
+		return nil, err
+	}
+
+	options := &requests.RequestOptions{
+		Retry:        retryPolicy,
+		OutputFormat: outputFormat,
+		Extract:      extractor,
+	}
+
+	if value := request.Parameters[consts.MaxRedirectsKey]; value != "" {
+		maxRedirects, err := strconv.Atoi(value)
+		if err != nil || maxRedirects < 1 {
+			return nil, fmt.Errorf("invalid %s: %s, must be a positive integer", consts.MaxRedirectsKey, value)
+		}
+		options.MaxRedirects = maxRedirects
+	}
+
+	if value := request.Parameters[consts.FollowRedirectsKey]; value != "" {
+		followRedirects, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s, must be a boolean", consts.FollowRedirectsKey, value)
+		}
+		options.DisableRedirects = !followRedirects
+	}
+
+	if sessionId := request.Parameters[consts.SessionIdKey]; sessionId != "" {
+		ttl, err := getSessionTtl(request)
+		if err != nil {
+			return nil, err
+		}
+		if options.Session, err = requests.GetSession(ctx, sessionId, ttl); err != nil {
+			return nil, err
+		}
+	}
+
+	return options, nil
+}
+
+func getSessionTtl(request *plugin.ExecuteActionRequest) (time.Duration, error) {
+	value := request.Parameters[consts.SessionTtlKey]
+	if value == "" {
+		return requests.DefaultSessionTtl, nil
+	}
+
+	ttl, err := strconv.Atoi(value)
+	if err != nil || ttl < 1 {
+		return 0, fmt.Errorf("invalid %s: %s, must be a positive number of seconds", consts.SessionTtlKey, value)
+	}
+	return time.Second * time.Duration(ttl), nil
+}
+
+// executeSessionAction returns the cookies of a session or clears it
+func executeSessionAction(ctx *plugin.ActionContext, request *plugin.ExecuteActionRequest, _ types.Plugin) ([]byte, error) {
+	sessionId, ok := request.Parameters[consts.SessionIdKey]
+	if !ok || sessionId == "" {
+		return nil, errors.New("no session id provided")
+	}
+
+	switch operation := request.Parameters[consts.SessionOperationKey]; operation {
+	case "", "get":
+		session := requests.LookupSession(ctx, sessionId)
+		if session == nil {
+			return nil, fmt.Errorf("session %s does not exist or expired", sessionId)
+		}
+		return json.Marshal(map[string]interface{}{
+			"session_id": sessionId,
+			"cookies":    session.GetCookies(),
+			"expires":    session.Expires().UTC().Format(time.RFC3339),
+		})
+	case "clear":
+		return json.Marshal(map[string]interface{}{
+			"session_id": sessionId,
+			"cleared":    requests.ClearSession(ctx, sessionId),
+		})
+	default:
+		return nil, fmt.Errorf("invalid %s: %s, must be get or clear", consts.SessionOperationKey, operation)
+	}
 }
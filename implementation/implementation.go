@@ -12,6 +12,7 @@ import (
 	"github.com/blinkops/blink-sdk/plugin/description"
 	log "github.com/sirupsen/logrus"
 	"path"
+	"unicode/utf8"
 )
 
 type HttpPlugin struct {
@@ -56,7 +57,8 @@ func (p *HttpPlugin) ExecuteAction(ctx *plugin.ActionContext, request *plugin.Ex
 
 	}
 
-	if len(resultBytes) > 0 && resultBytes[len(resultBytes)-1] == '\n' {
+	// the trailing newline of text results is dropped, binary results are returned untouched
+	if len(resultBytes) > 0 && resultBytes[len(resultBytes)-1] == '\n' && utf8.Valid(resultBytes) {
 		resultBytes = resultBytes[:len(resultBytes)-1]
 	}
 
@@ -112,12 +114,18 @@ func NewHTTPPlugin(rootPluginDirectory string) (*HttpPlugin, error) {
 	}
 
 	supportedActions := map[string] types.ActionHandler{
-		"get":     executeHTTPGetAction,
-		"post":    executeHTTPPostAction,
-		"put":     executeHTTPPutAction,
-		"delete":  executeHTTPDeleteAction,
-		"patch":   executeHTTPPatchAction,
-		"graphQL": executeGraphQL,
+		"get":       executeHTTPGetAction,
+		"post":      executeHTTPPostAction,
+		"put":       executeHTTPPutAction,
+		"delete":    executeHTTPDeleteAction,
+		"patch":     executeHTTPPatchAction,
+		"graphQL":   executeGraphQL,
+		"jsonRpc":   executeJsonRpc,
+		"request":   executeHTTPRequestAction,
+		"session":   executeSessionAction,
+		"websocket": executeWebSocket,
+		"sse":       executeSse,
+		"soap":      executeSoap,
 	}
 
 	for _, integration := range plugins.Plugins {
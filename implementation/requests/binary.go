@@ -0,0 +1,212 @@
+package requests
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultDownloadMaxSize is the size cap of downloads without one, 100 MiB
+const DefaultDownloadMaxSize = 100 << 20
+
+// textMediaTypes are the textual media types outside of text/* and the +json, +xml and +yaml suffixes
+var textMediaTypes = map[string]bool{
+	"application/json":                  true,
+	"application/x-ndjson":              true,
+	"application/xml":                   true,
+	"application/javascript":            true,
+	"application/ecmascript":            true,
+	"application/x-www-form-urlencoded": true,
+	"application/yaml":                  true,
+	"application/x-yaml":                true,
+	"application/graphql":               true,
+	"application/x-sh":                  true,
+	"application/sql":                   true,
+	"application/csv":                   true,
+}
+
+// BinaryBody is the JSON returned instead of a binary response body
+type BinaryBody struct {
+	ContentType string `json:"content_type"`
+	Encoding    string `json:"encoding"`
+	Size        int    `json:"size"`
+	Content     string `json:"content"`
+}
+
+// DownloadOptions streams successful response bodies to a local file instead of returning them
+type DownloadOptions struct {
+	// Path is the file to write, or the directory to write the file to when it ends with a separator or exists.
+	// the file is named after the Content-Disposition filename or the last segment of the url path
+	Path    string
+	MaxSize int64
+}
+
+// DownloadedFile is the JSON returned for a downloaded response body
+type DownloadedFile struct {
+	Path        string `json:"path"`
+	Size        int64  `json:"size"`
+	Sha256      string `json:"sha256"`
+	ContentType string `json:"content_type,omitempty"`
+}
+
+// encodeBinaryResponse returns binary bodies as a base64 BinaryBody and other bodies as they are
+func encodeBinaryResponse(response *http.Response, body []byte) ([]byte, error) {
+	if response == nil || len(body) == 0 {
+		return body, nil
+	}
+
+	mediaType, binary := getBinaryMediaType(response.Header.Get("Content-Type"), body)
+	if !binary {
+		return body, nil
+	}
+
+	encoded, err := json.Marshal(BinaryBody{
+		ContentType: mediaType,
+		Encoding:    "base64",
+		Size:        len(body),
+		Content:     base64.StdEncoding.EncodeToString(body),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode binary response, error: %v", err)
+	}
+	return encoded, nil
+}
+
+// getBinaryMediaType returns the media type of the body and whether it's binary. images, audio, video and fonts are
+// binary by their Content-Type, other non textual types and bodies without a Content-Type are sniffed
+func getBinaryMediaType(contentType string, body []byte) (string, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = ""
+	}
+
+	if isTextMediaType(mediaType) {
+		return mediaType, false
+	}
+	for _, prefix := range []string{"image/", "audio/", "video/", "font/"} {
+		if strings.HasPrefix(mediaType, prefix) {
+			return mediaType, true
+		}
+	}
+
+	sniffedType, _, _ := mime.ParseMediaType(http.DetectContentType(body))
+	if mediaType == "" || mediaType == "application/octet-stream" {
+		mediaType = sniffedType
+	}
+	return mediaType, !isTextMediaType(sniffedType)
+}
+
+func isTextMediaType(mediaType string) bool {
+	return strings.HasPrefix(mediaType, "text/") || textMediaTypes[mediaType] ||
+		strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") || strings.HasSuffix(mediaType, "+yaml")
+}
+
+// save streams the response body to the file and returns the DownloadedFile, the response body is closed.
+// the body is written to a temporary file that replaces the file once it's complete
+func (d *DownloadOptions) save(response *http.Response) ([]byte, error) {
+	defer func() {
+		_ = response.Body.Close()
+	}()
+
+	maxSize := d.getMaxSize()
+	if response.ContentLength > maxSize {
+		return nil, fmt.Errorf("the download size %d exceeds the maximum of %d bytes", response.ContentLength, maxSize)
+	}
+
+	filePath, err := filepath.Abs(d.getFilePath(response))
+	if err != nil {
+		return nil, fmt.Errorf("invalid download path, error: %v", err)
+	}
+	if err = os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create download directory, error: %v", err)
+	}
+	file, err := ioutil.TempFile(filepath.Dir(filePath), "."+filepath.Base(filePath)+".*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create download file, error: %v", err)
+	}
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(file, hash), io.LimitReader(response.Body, maxSize+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && size > maxSize {
+		err = fmt.Errorf("the download exceeds the maximum of %d bytes", maxSize)
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), filePath)
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return nil, fmt.Errorf("failed to download response, error: %v", err)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	return json.Marshal(DownloadedFile{
+		Path:        filePath,
+		Size:        size,
+		Sha256:      hex.EncodeToString(hash.Sum(nil)),
+		ContentType: mediaType,
+	})
+}
+
+func (d *DownloadOptions) getMaxSize() int64 {
+	if d.MaxSize <= 0 {
+		return DefaultDownloadMaxSize
+	}
+	return d.MaxSize
+}
+
+// maxSizeBody fails once more than maxSize bytes are read, so bodies larger than the cap aren't read whole
+type maxSizeBody struct {
+	body    io.ReadCloser
+	maxSize int64
+	read    int64
+}
+
+func (b *maxSizeBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	b.read += int64(n)
+	if b.read > b.maxSize {
+		return n, fmt.Errorf("the response exceeds the maximum of %d bytes", b.maxSize)
+	}
+	return n, err
+}
+
+func (b *maxSizeBody) Close() error {
+	return b.body.Close()
+}
+
+func (d *DownloadOptions) getFilePath(response *http.Response) string {
+	if !strings.HasSuffix(d.Path, string(os.PathSeparator)) && !strings.HasSuffix(d.Path, "/") {
+		if info, err := os.Stat(d.Path); err != nil || !info.IsDir() {
+			return d.Path
+		}
+	}
+	return filepath.Join(d.Path, getDownloadFileName(response))
+}
+
+// getDownloadFileName returns the base name of the Content-Disposition filename or of the url path
+func getDownloadFileName(response *http.Response) string {
+	name := ""
+	if _, params, err := mime.ParseMediaType(response.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		name = filepath.Base(filepath.FromSlash(strings.ReplaceAll(params["filename"], "\\", "/")))
+	} else if response.Request != nil && response.Request.URL != nil {
+		name = path.Base(response.Request.URL.Path)
+	}
+
+	if name == "" || name == "." || name == ".." || name == "/" || name == string(os.PathSeparator) {
+		return "download"
+	}
+	return name
+}
@@ -0,0 +1,162 @@
+package requests
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/blinkops/blink-http/consts"
+	"github.com/blinkops/blink-sdk/plugin"
+	"github.com/blinkops/blink-sdk/plugin/connections"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+var testPdf = []byte("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n1 0 obj\n<<>>\nendobj\n")
+
+type BinaryTestSuite struct {
+	suite.Suite
+	ctx    *plugin.ActionContext
+	server *httptest.Server
+}
+
+func TestBinaryTestSuite(t *testing.T) {
+	suite.Run(t, new(BinaryTestSuite))
+}
+
+func (suite *BinaryTestSuite) SetupTest() {
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/report.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			_, _ = w.Write(testPdf)
+		case "/attachment":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("Content-Disposition", `attachment; filename="../invoice.pdf"`)
+			_, _ = w.Write(testPdf)
+		case "/large":
+			// no Content-Length, so the size is only known while streaming
+			w.(http.Flusher).Flush()
+			_, _ = w.Write([]byte(strings.Repeat("a", 2048)))
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, "not found\n")
+		case "/missing.pdf":
+			w.Header().Set("Content-Type", "application/pdf")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write(testPdf)
+		default:
+			w.Header().Set("Content-Type", "text/plain")
+			_, _ = fmt.Fprint(w, "hello\n")
+		}
+	}))
+	suite.ctx = plugin.NewActionContext(nil, map[string]*connections.ConnectionInstance{
+		consts.ApiTokenKey: {Data: map[string]string{consts.RequestUrlKey: suite.server.URL}},
+	})
+}
+
+func (suite *BinaryTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func (suite *BinaryTestSuite) TestGetBinaryMediaType() {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	cases := []struct {
+		contentType string
+		body        []byte
+		mediaType   string
+		binary      bool
+	}{
+		{"application/json", []byte(`{"a": 1}`), "application/json", false},
+		{"application/vnd.api+json; charset=utf-8", []byte(`{}`), "application/vnd.api+json", false},
+		{"image/svg+xml", []byte(`<svg/>`), "image/svg+xml", false},
+		{"text/csv", []byte("a,b\n"), "text/csv", false},
+		{"image/png", png, "image/png", true},
+		{"application/pdf", testPdf, "application/pdf", true},
+		{"application/octet-stream", png, "image/png", true},
+		{"", png, "image/png", true},
+		{"", []byte("plain text"), "text/plain", false},
+		{"application/vnd.custom", []byte("plain text"), "application/vnd.custom", false},
+	}
+	for _, testCase := range cases {
+		mediaType, binary := getBinaryMediaType(testCase.contentType, testCase.body)
+		suite.Equal(testCase.mediaType, mediaType, testCase.contentType)
+		suite.Equal(testCase.binary, binary, testCase.contentType)
+	}
+}
+
+func (suite *BinaryTestSuite) TestBinaryResponse() {
+	body, err := SendRequestWithOptions(suite.ctx, nil, http.MethodGet, suite.server.URL+"/report.pdf", 5, nil, nil, nil, nil)
+	suite.Require().Nil(err)
+
+	binaryBody := BinaryBody{}
+	suite.Require().Nil(json.Unmarshal(body, &binaryBody))
+	suite.Equal("application/pdf", binaryBody.ContentType)
+	suite.Equal("base64", binaryBody.Encoding)
+	suite.Equal(len(testPdf), binaryBody.Size)
+	content, err := base64.StdEncoding.DecodeString(binaryBody.Content)
+	suite.Nil(err)
+	suite.Equal(testPdf, content)
+
+	body, err = SendRequestWithOptions(suite.ctx, nil, http.MethodGet, suite.server.URL+"/text", 5, nil, nil, nil, nil)
+	suite.Nil(err)
+	suite.Equal("hello\n", string(body))
+}
+
+func (suite *BinaryTestSuite) TestDownload() {
+	directory := suite.T().TempDir()
+	checksum := sha256.Sum256(testPdf)
+
+	filePath := filepath.Join(directory, "reports", "report.pdf")
+	body, err := SendRequestWithOptions(suite.ctx, nil, http.MethodGet, suite.server.URL+"/report.pdf", 5, nil, nil, nil, &RequestOptions{Download: &DownloadOptions{Path: filePath}})
+	suite.Require().Nil(err)
+	suite.JSONEq(fmt.Sprintf(`{"path": %q, "size": %d, "sha256": %q, "content_type": "application/pdf"}`, filePath, len(testPdf), hex.EncodeToString(checksum[:])), string(body))
+	content, err := ioutil.ReadFile(filePath)
+	suite.Nil(err)
+	suite.Equal(testPdf, content)
+
+	// a directory gets the file name of the response, without its path
+	body, err = SendRequestWithOptions(suite.ctx, nil, http.MethodGet, suite.server.URL+"/attachment", 5, nil, nil, nil, &RequestOptions{Download: &DownloadOptions{Path: directory}})
+	suite.Require().Nil(err)
+	downloaded := DownloadedFile{}
+	suite.Nil(json.Unmarshal(body, &downloaded))
+	suite.Equal(filepath.Join(directory, "invoice.pdf"), downloaded.Path)
+
+	largePath := filepath.Join(directory, "large.txt")
+	_, err = SendRequestWithOptions(suite.ctx, nil, http.MethodGet, suite.server.URL+"/large", 5, nil, nil, nil, &RequestOptions{Download: &DownloadOptions{Path: largePath, MaxSize: 1024}})
+	suite.NotNil(err)
+	_, err = os.Stat(largePath)
+	suite.True(os.IsNotExist(err))
+
+	_, err = SendRequestWithOptions(suite.ctx, nil, http.MethodGet, suite.server.URL+"/report.pdf", 5, nil, nil, nil, &RequestOptions{Download: &DownloadOptions{Path: largePath, MaxSize: 10}})
+	suite.NotNil(err)
+
+	// failed responses are returned rather than downloaded
+	body, err = SendRequestWithOptions(suite.ctx, nil, http.MethodGet, suite.server.URL+"/missing", 5, nil, nil, nil, &RequestOptions{Download: &DownloadOptions{Path: directory + "/"}})
+	suite.NotNil(err)
+	suite.Equal("not found\n", string(body))
+
+	// binary error bodies are encoded like those of other requests, and capped like the download
+	body, err = SendRequestWithOptions(suite.ctx, nil, http.MethodGet, suite.server.URL+"/missing.pdf", 5, nil, nil, nil, &RequestOptions{Download: &DownloadOptions{Path: directory + "/"}})
+	suite.EqualError(err, "status: 404")
+	binaryBody := BinaryBody{}
+	suite.Require().Nil(json.Unmarshal(body, &binaryBody))
+	suite.Equal("application/pdf", binaryBody.ContentType)
+	suite.Equal(base64.StdEncoding.EncodeToString(testPdf), binaryBody.Content)
+
+	_, err = SendRequestWithOptions(suite.ctx, nil, http.MethodGet, suite.server.URL+"/missing.pdf", 5, nil, nil, nil, &RequestOptions{Download: &DownloadOptions{Path: directory + "/", MaxSize: 10}})
+	suite.NotNil(err)
+	suite.Contains(err.Error(), "maximum of 10 bytes")
+
+	files, err := ioutil.ReadDir(directory)
+	suite.Nil(err)
+	suite.Len(files, 2)
+}
@@ -0,0 +1,128 @@
+package requests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io"
+	"mime"
+	"strings"
+)
+
+const (
+	ConvertResponseNone = "none"
+	ConvertResponseAuto = "auto"
+	ConvertResponseXml  = "xml"
+	ConvertResponseYaml = "yaml"
+)
+
+func ValidateResponseConversion(conversion string) error {
+	switch conversion {
+	case "", ConvertResponseNone, ConvertResponseAuto, ConvertResponseXml, ConvertResponseYaml:
+		return nil
+	default:
+		return fmt.Errorf("invalid response conversion: %s, must be one of: %s, %s, %s, %s", conversion,
+			ConvertResponseNone, ConvertResponseAuto, ConvertResponseXml, ConvertResponseYaml)
+	}
+}
+
+// ConvertResponse converts an XML or YAML body to JSON. The auto conversion picks the converter by the Content-Type
+// and leaves other bodies as they are, empty bodies are never converted
+func ConvertResponse(conversion string, contentType string, body []byte) ([]byte, error) {
+	if conversion == ConvertResponseAuto {
+		conversion = getResponseConversion(contentType)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return body, nil
+	}
+
+	switch conversion {
+	case ConvertResponseXml:
+		return convertXml(body)
+	case ConvertResponseYaml:
+		return convertYaml(body)
+	default:
+		return body, nil
+	}
+}
+
+// getResponseConversion returns the conversion of the XML and YAML media types, including the +xml and +yaml ones
+func getResponseConversion(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ConvertResponseNone
+	}
+
+	switch {
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return ConvertResponseXml
+	case mediaType == "application/yaml" || mediaType == "application/x-yaml" || mediaType == "text/yaml" ||
+		mediaType == "text/x-yaml" || strings.HasSuffix(mediaType, "+yaml"):
+		return ConvertResponseYaml
+	default:
+		return ConvertResponseNone
+	}
+}
+
+// convertXml returns the document as an object keyed by the local name of the root element, see xmlNode.value
+func convertXml(body []byte) ([]byte, error) {
+	root, err := parseXml(body)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]interface{}{root.name.Local: root.value()})
+}
+
+// convertYaml returns the document as JSON, a stream of several documents is returned as an array of them
+func convertYaml(body []byte) ([]byte, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(body))
+	var documents []interface{}
+	for {
+		var document interface{}
+		err := decoder.Decode(&document)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid yaml, error: %v", err)
+		}
+		documents = append(documents, getJsonValue(document))
+	}
+
+	var value interface{}
+	if len(documents) == 1 {
+		value = documents[0]
+	} else {
+		value = documents
+	}
+
+	converted, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert yaml to json, error: %v", err)
+	}
+	return converted, nil
+}
+
+// getJsonValue converts the yaml mappings, which can have keys of any type, to json objects
+func getJsonValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		object := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			name := "null"
+			if key != nil {
+				name = fmt.Sprint(key)
+			}
+			object[name] = getJsonValue(item)
+		}
+		return object
+	case []interface{}:
+		for i, item := range value {
+			value[i] = getJsonValue(item)
+		}
+		return value
+	default:
+		return value
+	}
+}
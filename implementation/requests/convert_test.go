@@ -0,0 +1,110 @@
+package requests
+
+import (
+	"fmt"
+	"github.com/blinkops/blink-http/consts"
+	"github.com/blinkops/blink-sdk/plugin"
+	"github.com/blinkops/blink-sdk/plugin/connections"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ConvertTestSuite struct {
+	suite.Suite
+}
+
+func TestConvertTestSuite(t *testing.T) {
+	suite.Run(t, new(ConvertTestSuite))
+}
+
+func (suite *ConvertTestSuite) TestValidateResponseConversion() {
+	for _, conversion := range []string{"", ConvertResponseNone, ConvertResponseAuto, ConvertResponseXml, ConvertResponseYaml} {
+		suite.Nil(ValidateResponseConversion(conversion))
+	}
+	suite.NotNil(ValidateResponseConversion("csv"))
+}
+
+func (suite *ConvertTestSuite) TestConvertXml() {
+	body := []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+	<Name>bucket</Name>
+	<Contents><Key>a.txt</Key><Size>10</Size></Contents>
+	<Contents><Key>b.txt</Key><Size>20</Size></Contents>
+	<Owner xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:nil="true"/>
+	<Tag type="env">prod</Tag>
+</ListBucketResult>`)
+
+	for _, contentType := range []string{"application/xml", "text/xml; charset=utf-8", "application/atom+xml"} {
+		converted, err := ConvertResponse(ConvertResponseAuto, contentType, body)
+		suite.Nil(err, contentType)
+		suite.JSONEq(`{"ListBucketResult": {
+			"Name": "bucket",
+			"Contents": [{"Key": "a.txt", "Size": "10"}, {"Key": "b.txt", "Size": "20"}],
+			"Owner": null,
+			"Tag": {"@type": "env", "#text": "prod"}
+		}}`, string(converted), contentType)
+	}
+
+	converted, err := ConvertResponse(ConvertResponseAuto, "text/plain", body)
+	suite.Nil(err)
+	suite.Equal(body, converted)
+
+	converted, err = ConvertResponse(ConvertResponseXml, "text/plain", []byte(`<a><b>1</b></a>`))
+	suite.Nil(err)
+	suite.JSONEq(`{"a": {"b": "1"}}`, string(converted))
+
+	_, err = ConvertResponse(ConvertResponseXml, "", []byte(`{"a": 1}`))
+	suite.NotNil(err)
+}
+
+func (suite *ConvertTestSuite) TestConvertYaml() {
+	body := []byte("name: blink\nreplicas: 3\nports:\n  - 80\n  - 443\nlabels:\n  1: one\n")
+	converted, err := ConvertResponse(ConvertResponseAuto, "application/x-yaml", body)
+	suite.Nil(err)
+	suite.JSONEq(`{"name": "blink", "replicas": 3, "ports": [80, 443], "labels": {"1": "one"}}`, string(converted))
+
+	converted, err = ConvertResponse(ConvertResponseYaml, "text/plain", []byte("a: 1\n---\na: 2\n"))
+	suite.Nil(err)
+	suite.JSONEq(`[{"a": 1}, {"a": 2}]`, string(converted))
+
+	converted, err = ConvertResponse(ConvertResponseYaml, "application/yaml", []byte(""))
+	suite.Nil(err)
+	suite.Equal("", string(converted))
+
+	_, err = ConvertResponse(ConvertResponseYaml, "", []byte("a: [1"))
+	suite.NotNil(err)
+}
+
+func (suite *ConvertTestSuite) TestSendRequestWithConversion() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, `<Error><Code>NoSuchKey</Code></Error>`)
+			return
+		}
+		_, _ = fmt.Fprint(w, `<Status><State>ok</State></Status>`)
+	}))
+	defer server.Close()
+
+	ctx := plugin.NewActionContext(nil, map[string]*connections.ConnectionInstance{
+		consts.ApiTokenKey: {Data: map[string]string{consts.RequestUrlKey: server.URL}},
+	})
+
+	extractor, err := NewExtractor("Status.State")
+	suite.Require().Nil(err)
+	body, err := SendRequestWithOptions(ctx, nil, http.MethodGet, server.URL, 5, nil, nil, nil, &RequestOptions{ConvertResponse: ConvertResponseAuto, Extract: extractor})
+	suite.Nil(err)
+	suite.Equal("ok", string(body))
+
+	body, err = SendRequestWithOptions(ctx, nil, http.MethodGet, server.URL+"/missing", 5, nil, nil, nil, &RequestOptions{ConvertResponse: ConvertResponseAuto})
+	suite.NotNil(err)
+	suite.JSONEq(`{"Error": {"Code": "NoSuchKey"}}`, string(body))
+
+	body, err = SendRequestWithOptions(ctx, nil, http.MethodGet, server.URL, 5, nil, nil, nil, &RequestOptions{ConvertResponse: ConvertResponseNone})
+	suite.Nil(err)
+	suite.Equal(`<Status><State>ok</State></Status>`, string(body))
+}
@@ -0,0 +1,95 @@
+package requests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	OutputFormatRaw      = "raw"
+	OutputFormatEnvelope = "envelope"
+)
+
+type ResponseEnvelope struct {
+	StatusCode int                 `json:"status_code"`
+	Reason     string              `json:"reason"`
+	Headers    map[string][]string `json:"headers"`
+	Cookies    []EnvelopeCookie    `json:"cookies"`
+	Url        string              `json:"url"`
+	ElapsedMs  int64               `json:"elapsed_ms"`
+	Body       interface{}         `json:"body"`
+}
+
+type EnvelopeCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Domain   string `json:"domain,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Expires  string `json:"expires,omitempty"`
+	MaxAge   int    `json:"max_age,omitempty"`
+	Secure   bool   `json:"secure"`
+	HttpOnly bool   `json:"http_only"`
+}
+
+func ValidateOutputFormat(outputFormat string) error {
+	switch outputFormat {
+	case "", OutputFormatRaw, OutputFormatEnvelope:
+		return nil
+	default:
+		return fmt.Errorf("invalid output format: %s, must be one of: %s, %s", outputFormat, OutputFormatRaw, OutputFormatEnvelope)
+	}
+}
+
+func NewResponseEnvelope(response *http.Response, body []byte, elapsed time.Duration) *ResponseEnvelope {
+	envelope := &ResponseEnvelope{
+		StatusCode: response.StatusCode,
+		Reason:     getReason(response),
+		Headers:    response.Header,
+		Cookies:    []EnvelopeCookie{},
+		ElapsedMs:  elapsed.Milliseconds(),
+		Body:       getEnvelopeBody(body),
+	}
+
+	// the request attached to the response is the last one sent, after following redirects
+	if response.Request != nil && response.Request.URL != nil {
+		envelope.Url = response.Request.URL.String()
+	}
+
+	for _, cookie := range response.Cookies() {
+		envelopeCookie := EnvelopeCookie{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   cookie.Domain,
+			Path:     cookie.Path,
+			MaxAge:   cookie.MaxAge,
+			Secure:   cookie.Secure,
+			HttpOnly: cookie.HttpOnly,
+		}
+		if !cookie.Expires.IsZero() {
+			envelopeCookie.Expires = cookie.Expires.UTC().Format(time.RFC3339)
+		}
+		envelope.Cookies = append(envelope.Cookies, envelopeCookie)
+	}
+
+	return envelope
+}
+
+func getReason(response *http.Response) string {
+	reason := strings.TrimPrefix(response.Status, strconv.Itoa(response.StatusCode)+" ")
+	if reason == "" || reason == response.Status {
+		return http.StatusText(response.StatusCode)
+	}
+	return reason
+}
+
+// getEnvelopeBody embeds json bodies as is and everything else as a string
+func getEnvelopeBody(body []byte) interface{} {
+	if len(body) > 0 && json.Valid(body) {
+		return json.RawMessage(body)
+	}
+	return string(body)
+}
@@ -0,0 +1,64 @@
+package requests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type EnvelopeTestSuite struct {
+	suite.Suite
+}
+
+func TestEnvelopeTestSuite(t *testing.T) {
+	suite.Run(t, new(EnvelopeTestSuite))
+}
+
+func (suite *EnvelopeTestSuite) TestValidateOutputFormat() {
+	suite.Nil(ValidateOutputFormat(""))
+	suite.Nil(ValidateOutputFormat(OutputFormatRaw))
+	suite.Nil(ValidateOutputFormat(OutputFormatEnvelope))
+	suite.NotNil(ValidateOutputFormat("xml"))
+}
+
+func (suite *EnvelopeTestSuite) TestNewResponseEnvelope() {
+	finalUrl, err := url.Parse("https://host.com/final")
+	suite.Nil(err)
+
+	response := &http.Response{
+		StatusCode: http.StatusCreated,
+		Status:     "201 Created",
+		Header: http.Header{
+			"Location":   []string{"https://host.com/items/1"},
+			"Set-Cookie": []string{"session=abc; Path=/; HttpOnly; Secure"},
+		},
+		Request: &http.Request{URL: finalUrl},
+	}
+
+	envelope := NewResponseEnvelope(response, []byte(`{"id":1}`), 1500*time.Millisecond)
+	suite.Equal(http.StatusCreated, envelope.StatusCode)
+	suite.Equal("Created", envelope.Reason)
+	suite.Equal("https://host.com/final", envelope.Url)
+	suite.Equal(int64(1500), envelope.ElapsedMs)
+	suite.Equal([]string{"https://host.com/items/1"}, envelope.Headers["Location"])
+	suite.Len(envelope.Cookies, 1)
+	suite.Equal("session", envelope.Cookies[0].Name)
+	suite.Equal("abc", envelope.Cookies[0].Value)
+	suite.True(envelope.Cookies[0].HttpOnly)
+	suite.True(envelope.Cookies[0].Secure)
+
+	marshaled, err := json.Marshal(envelope)
+	suite.Nil(err)
+
+	var decoded map[string]interface{}
+	suite.Nil(json.Unmarshal(marshaled, &decoded))
+	suite.Equal(map[string]interface{}{"id": float64(1)}, decoded["body"])
+
+	// non json bodies are returned as strings
+	envelope = NewResponseEnvelope(response, []byte("not json"), 0)
+	suite.Equal("not json", envelope.Body)
+}
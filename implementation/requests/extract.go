@@ -0,0 +1,222 @@
+package requests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/jmespath/go-jmespath"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// maxExactInteger is the largest integer a float64 holds exactly, 2^53
+const maxExactInteger = 1 << 53
+
+// Extractor evaluates a JMESPath expression against a json response.
+// expressions starting with $ are treated as JSONPath and translated to JMESPath.
+type Extractor struct {
+	expression string
+	compiled   *jmespath.JMESPath
+}
+
+// NewExtractor compiles the expression, so invalid expressions fail before the request is sent
+func NewExtractor(expression string) (*Extractor, error) {
+	expression = strings.TrimSpace(expression)
+	if expression == "" {
+		return nil, nil
+	}
+
+	jmesPathExpression := expression
+	if strings.HasPrefix(expression, "$") {
+		translated, err := jsonPathToJMESPath(expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extract expression: %s, error: %v", expression, err)
+		}
+		jmesPathExpression = translated
+	}
+
+	compiled, err := jmespath.Compile(jmesPathExpression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extract expression: %s, error: %v", expression, err)
+	}
+
+	return &Extractor{expression: expression, compiled: compiled}, nil
+}
+
+// Extract returns the result of the expression. string results are returned as is,
+// so a single field can be passed to the next step without its quotes, anything else is returned as json.
+func (e *Extractor) Extract(body []byte) ([]byte, error) {
+	result, err := e.search(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if value, ok := result.(string); ok {
+		return []byte(value), nil
+	}
+
+	return json.Marshal(result)
+}
+
+// extractJson returns the result of the expression as json, strings included, for results that are embedded in a json response
+func (e *Extractor) extractJson(body []byte) ([]byte, error) {
+	result, err := e.search(body)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(result)
+}
+
+func (e *Extractor) search(body []byte) (interface{}, error) {
+	decoded, err := decodeJson(body)
+	if err != nil {
+		return nil, fmt.Errorf("can't extract %s, the response is not a valid json, error: %v", e.expression, err)
+	}
+
+	result, err := e.compiled.Search(decoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate extract expression: %s, error: %v", e.expression, err)
+	}
+	return result, nil
+}
+
+// Matches reports whether the expression has a truthy result for the json value. null, false and empty
+// strings, arrays and objects don't match, like in JMESPath conditions. values that aren't json never match.
+func (e *Extractor) Matches(body []byte) bool {
+	decoded, err := decodeJson(body)
+	if err != nil {
+		return false
+	}
+
+	result, err := e.compiled.Search(decoded)
+	if err != nil {
+		return false
+	}
+
+	switch value := result.(type) {
+	case nil:
+		return false
+	case bool:
+		return value
+	case string:
+		return value != ""
+	case []interface{}:
+		return len(value) > 0
+	case map[string]interface{}:
+		return len(value) > 0
+	}
+	return true
+}
+
+// decodeJson decodes the body for JMESPath, which needs float64 numbers to compare them. integers that a float64
+// can't hold exactly, like large ids, are kept as json.Number so they are returned as is. they can be compared with
+// to_string, for example items[?to_string(id) == '12345678901234567890']
+func decodeJson(body []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("invalid data after the json value")
+	}
+	return getJMESPathValue(decoded), nil
+}
+
+func getJMESPathValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case json.Number:
+		if !strings.ContainsAny(string(value), ".eE") {
+			integer, err := value.Int64()
+			if err != nil || integer > maxExactInteger || integer < -maxExactInteger {
+				return value
+			}
+		}
+		number, err := value.Float64()
+		if err != nil {
+			return value
+		}
+		return number
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = getJMESPathValue(item)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = getJMESPathValue(item)
+		}
+	}
+	return value
+}
+
+// jsonPathToJMESPath translates the JSONPath subset that has a JMESPath equivalent:
+// $, .field, ['field'], [index], [*] and .*
+func jsonPathToJMESPath(path string) (string, error) {
+	if strings.Contains(path, "..") {
+		return "", fmt.Errorf("recursive descent (..) is not supported, use a JMESPath expression instead")
+	}
+
+	rest := strings.TrimPrefix(path, "$")
+	builder := strings.Builder{}
+
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			field := rest[:end]
+			if field == "" {
+				return "", fmt.Errorf("empty field name in %s", path)
+			}
+			writeField(&builder, field)
+			rest = rest[end:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return "", fmt.Errorf("unclosed bracket in %s", path)
+			}
+			selector := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+
+			if len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0] {
+				writeField(&builder, selector[1:len(selector)-1])
+				continue
+			}
+			if selector == "*" {
+				builder.WriteString("[*]")
+				continue
+			}
+			if _, err := strconv.Atoi(selector); err == nil {
+				builder.WriteString("[" + selector + "]")
+				continue
+			}
+			return "", fmt.Errorf("unsupported selector [%s], use a JMESPath expression instead", selector)
+		default:
+			return "", fmt.Errorf("unexpected %s in %s", rest, path)
+		}
+	}
+
+	if builder.Len() == 0 {
+		return "@", nil
+	}
+	return builder.String(), nil
+}
+
+func writeField(builder *strings.Builder, field string) {
+	if builder.Len() > 0 {
+		builder.WriteString(".")
+	}
+	if field == "*" {
+		builder.WriteString("*")
+		return
+	}
+	quoted, _ := json.Marshal(field)
+	builder.Write(quoted)
+}
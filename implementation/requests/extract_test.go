@@ -0,0 +1,74 @@
+package requests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ExtractTestSuite struct {
+	suite.Suite
+}
+
+func TestExtractTestSuite(t *testing.T) {
+	suite.Run(t, new(ExtractTestSuite))
+}
+
+func (suite *ExtractTestSuite) TestExtract() {
+	body := []byte(`{"total": 2, "items": [{"id": "a1", "name": "first"}, {"id": "b2", "name": "second"}], "meta": {"next page": null}}`)
+
+	for expression, expected := range map[string]string{
+		"total":                 `2`,
+		"items[0].id":           `a1`,
+		"items[*].name":         `["first","second"]`,
+		"length(items)":         `2`,
+		"items[?id=='b2'].name": `["second"]`,
+		"missing":               `null`,
+		"$.items[1].id":         `b2`,
+		"$['items'][*].name":    `["first","second"]`,
+		"$.meta['next page']":   `null`,
+		"$":                     `{"items":[{"id":"a1","name":"first"},{"id":"b2","name":"second"}],"meta":{"next page":null},"total":2}`,
+	} {
+		extractor, err := NewExtractor(expression)
+		suite.Require().Nil(err, expression)
+		result, err := extractor.Extract(body)
+		suite.Nil(err, expression)
+		suite.Equal(expected, string(result), expression)
+	}
+
+	extractor, err := NewExtractor("  ")
+	suite.Nil(err)
+	suite.Nil(extractor)
+
+	for _, badExpression := range []string{"items[", "$..id", "$.items[?(@.id)]", "$.items[0"} {
+		_, err = NewExtractor(badExpression)
+		suite.NotNil(err, badExpression)
+	}
+
+	extractor, err = NewExtractor("items")
+	suite.Require().Nil(err)
+	_, err = extractor.Extract([]byte("<html></html>"))
+	suite.NotNil(err)
+	_, err = extractor.Extract([]byte(`{"items": []} trailing`))
+	suite.NotNil(err)
+}
+
+func (suite *ExtractTestSuite) TestExtractLargeNumbers() {
+	body := []byte(`{"items": [{"id": 12345678901234567890, "count": 3, "ratio": 0.25}, {"id": -9007199254740993, "count": 10, "ratio": 1e3}]}`)
+
+	for expression, expected := range map[string]string{
+		"items[0].id":            `12345678901234567890`,
+		"items[*].id":            `[12345678901234567890,-9007199254740993]`,
+		"items[?count > `5`].id": `[-9007199254740993]`,
+		"items[?to_string(id) == '12345678901234567890'].count": `[3]`,
+		"sum(items[*].count)": `13`,
+		"items[*].ratio":      `[0.25,1000]`,
+		"items[0]":            `{"count":3,"id":12345678901234567890,"ratio":0.25}`,
+	} {
+		extractor, err := NewExtractor(expression)
+		suite.Require().Nil(err, expression)
+		result, err := extractor.Extract(body)
+		suite.Nil(err, expression)
+		suite.Equal(expected, string(result), expression)
+	}
+}
@@ -0,0 +1,284 @@
+package requests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/blinkops/blink-http/consts"
+	"github.com/blinkops/blink-http/plugins/types"
+	"github.com/blinkops/blink-sdk/plugin"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultGraphQLCursorVariable = "after"
+
+type GraphQLRequest struct {
+	Query         string          `json:"query"`
+	Variables     json.RawMessage `json:"variables,omitempty"`
+	OperationName string          `json:"operationName,omitempty"`
+}
+
+// GraphQLPartialResponse is returned when the server resolved only part of the query,
+// the errors describe the fields that are missing from the data
+type GraphQLPartialResponse struct {
+	Data    json.RawMessage   `json:"data"`
+	Errors  []json.RawMessage `json:"errors"`
+	Partial bool              `json:"partial"`
+}
+
+// GraphQLPagination follows the pageInfo { endCursor hasNextPage } of a Relay connection,
+// for example data.repository.issues, and collects the nodes of all the pages
+type GraphQLPagination struct {
+	ConnectionPath string
+	CursorVariable string
+	MaxPages       int
+	MaxItems       int
+}
+
+type graphQLResponse struct {
+	Data   json.RawMessage   `json:"data"`
+	Errors []json.RawMessage `json:"errors"`
+}
+
+type graphQLError struct {
+	Message string `json:"message"`
+}
+
+// NewGraphQLRequest validates the variables, which must be a JSON object when given
+func NewGraphQLRequest(query string, variables string, operationName string) (*GraphQLRequest, error) {
+	request := &GraphQLRequest{Query: query, OperationName: operationName}
+
+	variables = strings.TrimSpace(variables)
+	if variables == "" || variables == "null" {
+		return request, nil
+	}
+
+	var object map[string]interface{}
+	if err := json.Unmarshal([]byte(variables), &object); err != nil {
+		return nil, fmt.Errorf("graphql variables must be a JSON object, error: %v", err)
+	}
+	request.Variables = json.RawMessage(variables)
+	return request, nil
+}
+
+// SendGraphQLRequest posts the request and checks the errors of the response, which are returned with a successful status.
+// a response without data fails, a response with both data and errors is returned as a GraphQLPartialResponse
+func SendGraphQLRequest(ctx *plugin.ActionContext, plugin types.Plugin, urlString string, timeout int32, headers http.Header, request *GraphQLRequest, options *RequestOptions) ([]byte, error) {
+	if options == nil {
+		options = &RequestOptions{}
+	}
+
+	start := time.Now()
+	response, body, err := sendGraphQLRequest(ctx, plugin, urlString, timeout, headers, request, options)
+	return formatResponse(http.MethodPost, response, body, err, time.Since(start), options)
+}
+
+func sendGraphQLRequest(ctx *plugin.ActionContext, plugin types.Plugin, urlString string, timeout int32, headers http.Header, request *GraphQLRequest, options *RequestOptions) (*http.Response, []byte, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if headers == nil {
+		headers = http.Header{}
+	}
+	if headers.Get("Content-Type") == "" {
+		headers.Set("Content-Type", "application/json")
+	}
+
+	response, body, err := sendRequest(ctx, plugin, http.MethodPost, urlString, timeout, headers, nil, data, options)
+	if err != nil {
+		return response, body, err
+	}
+
+	body, err = checkGraphQLResponse(body)
+	return response, body, err
+}
+
+func checkGraphQLResponse(body []byte) ([]byte, error) {
+	parsed := graphQLResponse{}
+	// responses that aren't GraphQL responses are returned as is
+	if err := json.Unmarshal(body, &parsed); err != nil || len(parsed.Errors) == 0 {
+		return body, nil
+	}
+
+	if len(parsed.Data) == 0 || bytes.Equal(parsed.Data, []byte("null")) {
+		return body, fmt.Errorf("graphql request failed: %s", getGraphQLErrorMessages(parsed.Errors))
+	}
+
+	partial, err := json.Marshal(GraphQLPartialResponse{
+		Data:    parsed.Data,
+		Errors:  parsed.Errors,
+		Partial: true,
+	})
+	if err != nil {
+		return nil, errors.New("failed to marshal the partial graphql response")
+	}
+	return partial, nil
+}
+
+func getGraphQLErrorMessages(graphQLErrors []json.RawMessage) string {
+	messages := make([]string, 0, len(graphQLErrors))
+	for _, rawError := range graphQLErrors {
+		parsed := graphQLError{}
+		if err := json.Unmarshal(rawError, &parsed); err != nil || parsed.Message == "" {
+			messages = append(messages, string(rawError))
+			continue
+		}
+		messages = append(messages, parsed.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// ParseGraphQLPagination returns nil when no connection path is given
+func ParseGraphQLPagination(parameters map[string]string) (*GraphQLPagination, error) {
+	connectionPath := strings.TrimSpace(parameters[consts.PaginationConnectionPathKey])
+	if connectionPath == "" {
+		return nil, nil
+	}
+
+	pagination := &GraphQLPagination{
+		ConnectionPath: connectionPath,
+		CursorVariable: strings.TrimSpace(parameters[consts.PaginationCursorVariableKey]),
+		MaxPages:       defaultPaginationMaxPages,
+	}
+	if pagination.CursorVariable == "" {
+		pagination.CursorVariable = defaultGraphQLCursorVariable
+	}
+
+	for key, field := range map[string]*int{
+		consts.PaginationMaxPagesKey: &pagination.MaxPages,
+		consts.PaginationMaxItemsKey: &pagination.MaxItems,
+	} {
+		value := strings.TrimSpace(parameters[key])
+		if value == "" {
+			continue
+		}
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 0 {
+			return nil, fmt.Errorf("invalid %s: %s, must be a non negative integer", key, value)
+		}
+		*field = number
+	}
+
+	if pagination.MaxPages == 0 {
+		return nil, fmt.Errorf("%s must be greater than 0", consts.PaginationMaxPagesKey)
+	}
+	return pagination, nil
+}
+
+// SendPaginatedGraphQLRequest sends the request with the cursor of every page in the cursor variable and returns
+// the nodes of all the pages as a single json array. when pages have errors the nodes, after the extract expression
+// is applied to them, are returned in a GraphQLPartialResponse
+func SendPaginatedGraphQLRequest(ctx *plugin.ActionContext, plugin types.Plugin, urlString string, timeout int32, headers http.Header, request *GraphQLRequest, options *RequestOptions, pagination *GraphQLPagination) ([]byte, error) {
+	if options == nil {
+		options = &RequestOptions{}
+	}
+
+	variables := map[string]interface{}{}
+	if len(request.Variables) > 0 {
+		if err := json.Unmarshal(request.Variables, &variables); err != nil {
+			return nil, fmt.Errorf("graphql variables must be a JSON object, error: %v", err)
+		}
+	}
+	pageRequest := *request
+
+	nodes := []interface{}{}
+	var pageErrors []json.RawMessage
+	for pageCount := 1; pageCount <= pagination.MaxPages; pageCount++ {
+		pageVariables, err := json.Marshal(variables)
+		if err != nil {
+			return nil, err
+		}
+		pageRequest.Variables = pageVariables
+
+		_, body, err := sendGraphQLRequest(ctx, plugin, urlString, timeout, headers, &pageRequest, options)
+		if err != nil {
+			return body, err
+		}
+
+		var page struct {
+			Data   interface{}       `json:"data"`
+			Errors []json.RawMessage `json:"errors"`
+		}
+		if err = json.Unmarshal(body, &page); err != nil {
+			return body, fmt.Errorf("page %d is not a valid json, error: %v", pageCount, err)
+		}
+		pageErrors = append(pageErrors, page.Errors...)
+
+		// the path may start at the response (data.viewer.repositories) or at its data (viewer.repositories)
+		connection, ok := getJsonPath(map[string]interface{}{"data": page.Data}, pagination.ConnectionPath)
+		if !ok {
+			connection, ok = getJsonPath(page.Data, pagination.ConnectionPath)
+		}
+		if !ok || connection == nil {
+			return body, fmt.Errorf("page %d does not contain a connection at %s", pageCount, pagination.ConnectionPath)
+		}
+		pageNodes, err := getConnectionNodes(connection)
+		if err != nil {
+			return body, err
+		}
+		nodes = append(nodes, pageNodes...)
+
+		if pagination.MaxItems > 0 && len(nodes) >= pagination.MaxItems {
+			nodes = nodes[:pagination.MaxItems]
+			break
+		}
+
+		hasNextPage, _ := getJsonPath(connection, "pageInfo.hasNextPage")
+		endCursor, _ := getJsonPath(connection, "pageInfo.endCursor")
+		if hasNextPage != true || endCursor == nil || endCursor == "" {
+			break
+		}
+		variables[pagination.CursorVariable] = endCursor
+	}
+
+	merged, err := json.Marshal(nodes)
+	if err != nil {
+		return nil, err
+	}
+	if len(pageErrors) == 0 {
+		if options.Extract == nil {
+			return merged, nil
+		}
+		return options.Extract.Extract(merged)
+	}
+
+	// the extract expression applies to the nodes, so it's evaluated before they're wrapped with the errors
+	if options.Extract != nil {
+		if merged, err = options.Extract.extractJson(merged); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(GraphQLPartialResponse{Data: merged, Errors: pageErrors, Partial: true})
+}
+
+// getConnectionNodes returns the nodes of the connection, or the node of each of its edges
+func getConnectionNodes(connection interface{}) ([]interface{}, error) {
+	if nodes, ok := getJsonPath(connection, "nodes"); ok && nodes != nil {
+		if array, ok := nodes.([]interface{}); ok {
+			return array, nil
+		}
+		return nil, errors.New("the nodes of the connection are not an array")
+	}
+
+	edges, ok := getJsonPath(connection, "edges")
+	if !ok || edges == nil {
+		return nil, errors.New("the connection does not contain nodes or edges")
+	}
+	array, ok := edges.([]interface{})
+	if !ok {
+		return nil, errors.New("the edges of the connection are not an array")
+	}
+
+	nodes := make([]interface{}, 0, len(array))
+	for _, edge := range array {
+		node, _ := getJsonPath(edge, "node")
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
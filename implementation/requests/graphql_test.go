@@ -0,0 +1,157 @@
+package requests
+
+import (
+	"encoding/json"
+	"github.com/blinkops/blink-http/consts"
+	"github.com/blinkops/blink-sdk/plugin"
+	"github.com/blinkops/blink-sdk/plugin/connections"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type GraphQLTestSuite struct {
+	suite.Suite
+}
+
+func TestGraphQLTestSuite(t *testing.T) {
+	suite.Run(t, new(GraphQLTestSuite))
+}
+
+func (suite *GraphQLTestSuite) TestNewGraphQLRequest() {
+	request, err := NewGraphQLRequest("query Q($id: ID!) { node(id: $id) { id } }", `{"id": "1"}`, "Q")
+	suite.Nil(err)
+	body, err := json.Marshal(request)
+	suite.Nil(err)
+	suite.JSONEq(`{"query": "query Q($id: ID!) { node(id: $id) { id } }", "variables": {"id": "1"}, "operationName": "Q"}`, string(body))
+
+	request, err = NewGraphQLRequest("{ viewer { id } }", "", "")
+	suite.Nil(err)
+	body, err = json.Marshal(request)
+	suite.Nil(err)
+	suite.JSONEq(`{"query": "{ viewer { id } }"}`, string(body))
+
+	for _, variables := range []string{`"{}"`, `[1]`, `{"id": `} {
+		_, err = NewGraphQLRequest("{ viewer { id } }", variables, "")
+		suite.NotNil(err, variables)
+	}
+}
+
+func (suite *GraphQLTestSuite) TestSendGraphQLRequest() {
+	var response string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suite.Equal("application/json", r.Header.Get("Content-Type"))
+		body, err := ioutil.ReadAll(r.Body)
+		suite.Nil(err)
+		suite.JSONEq(`{"query": "{ viewer { id name } }", "variables": {"first": 1}}`, string(body))
+		_, _ = w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	ctx := plugin.NewActionContext(nil, map[string]*connections.ConnectionInstance{
+		consts.ApiTokenKey: {Data: map[string]string{consts.RequestUrlKey: server.URL}},
+	})
+	request, err := NewGraphQLRequest("{ viewer { id name } }", `{"first": 1}`, "")
+	suite.Require().Nil(err)
+
+	response = `{"data": {"viewer": {"id": "1", "name": "blink"}}}`
+	body, err := SendGraphQLRequest(ctx, nil, server.URL, 5, nil, request, nil)
+	suite.Nil(err)
+	suite.JSONEq(response, string(body))
+
+	response = `{"data": {"viewer": {"id": "1", "name": null}}, "errors": [{"message": "name is hidden", "path": ["viewer", "name"]}]}`
+	body, err = SendGraphQLRequest(ctx, nil, server.URL, 5, nil, request, nil)
+	suite.Nil(err)
+	suite.JSONEq(`{"data": {"viewer": {"id": "1", "name": null}}, "errors": [{"message": "name is hidden", "path": ["viewer", "name"]}], "partial": true}`, string(body))
+
+	response = `{"data": null, "errors": [{"message": "not authorized"}, {"message": "rate limited"}]}`
+	body, err = SendGraphQLRequest(ctx, nil, server.URL, 5, nil, request, nil)
+	suite.EqualError(err, "graphql request failed: not authorized; rate limited")
+	suite.JSONEq(response, string(body))
+
+	response = `{"errors": [{"extensions": {"code": "UNAUTHENTICATED"}}]}`
+	_, err = SendGraphQLRequest(ctx, nil, server.URL, 5, nil, request, nil)
+	suite.EqualError(err, `graphql request failed: {"extensions": {"code": "UNAUTHENTICATED"}}`)
+}
+
+func (suite *GraphQLTestSuite) TestSendPaginatedGraphQLRequest() {
+	pages := map[string]string{
+		"":   `{"data": {"viewer": {"repositories": {"nodes": [{"id": 1}, {"id": 2}], "pageInfo": {"endCursor": "c1", "hasNextPage": true}}}}}`,
+		"c1": `{"data": {"viewer": {"repositories": {"edges": [{"node": {"id": 3}}], "pageInfo": {"endCursor": "c2", "hasNextPage": true}}}}}`,
+		"c2": `{"data": {"viewer": {"repositories": {"nodes": [{"id": 4}], "pageInfo": {"endCursor": null, "hasNextPage": false}}}}}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		suite.Nil(json.NewDecoder(r.Body).Decode(&request))
+		suite.Equal(float64(2), request.Variables["first"])
+		cursor, _ := request.Variables["cursor"].(string)
+		_, _ = w.Write([]byte(pages[cursor]))
+	}))
+	defer server.Close()
+
+	ctx := plugin.NewActionContext(nil, map[string]*connections.ConnectionInstance{
+		consts.ApiTokenKey: {Data: map[string]string{consts.RequestUrlKey: server.URL}},
+	})
+	request, err := NewGraphQLRequest("query($first: Int, $cursor: String) { viewer { repositories(first: $first, after: $cursor) { nodes { id } } } }", `{"first": 2}`, "")
+	suite.Require().Nil(err)
+
+	pagination, err := ParseGraphQLPagination(map[string]string{
+		consts.PaginationConnectionPathKey: "data.viewer.repositories",
+		consts.PaginationCursorVariableKey: "cursor",
+	})
+	suite.Require().Nil(err)
+
+	body, err := SendPaginatedGraphQLRequest(ctx, nil, server.URL, 5, nil, request, nil, pagination)
+	suite.Nil(err)
+	suite.JSONEq(`[{"id": 1}, {"id": 2}, {"id": 3}, {"id": 4}]`, string(body))
+
+	pagination.ConnectionPath = "viewer.repositories"
+	pagination.MaxItems = 3
+	body, err = SendPaginatedGraphQLRequest(ctx, nil, server.URL, 5, nil, request, nil, pagination)
+	suite.Nil(err)
+	suite.JSONEq(`[{"id": 1}, {"id": 2}, {"id": 3}]`, string(body))
+
+	pagination.ConnectionPath = "data.viewer.issues"
+	_, err = SendPaginatedGraphQLRequest(ctx, nil, server.URL, 5, nil, request, nil, pagination)
+	suite.NotNil(err)
+
+	pagination, err = ParseGraphQLPagination(map[string]string{})
+	suite.Nil(err)
+	suite.Nil(pagination)
+}
+
+func (suite *GraphQLTestSuite) TestSendPaginatedGraphQLRequestExtractPartial() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data": {"viewer": {"repositories": {"nodes": [{"id": 1, "name": "a"}, {"id": 2, "name": null}], "pageInfo": {"hasNextPage": false}}}},
+			"errors": [{"message": "name is forbidden", "path": ["viewer", "repositories", "nodes", 1, "name"]}]}`))
+	}))
+	defer server.Close()
+
+	ctx := plugin.NewActionContext(nil, map[string]*connections.ConnectionInstance{
+		consts.ApiTokenKey: {Data: map[string]string{consts.RequestUrlKey: server.URL}},
+	})
+	request, err := NewGraphQLRequest("{ viewer { repositories { nodes { id name } } } }", "", "")
+	suite.Require().Nil(err)
+	pagination, err := ParseGraphQLPagination(map[string]string{
+		consts.PaginationConnectionPathKey: "data.viewer.repositories",
+		consts.PaginationCursorVariableKey: "cursor",
+	})
+	suite.Require().Nil(err)
+
+	// the expression is applied to the nodes rather than to the partial response that wraps them
+	for expression, data := range map[string]string{
+		"[].id":    `[1, 2]`,
+		"[0].name": `"a"`,
+	} {
+		extractor, err := NewExtractor(expression)
+		suite.Require().Nil(err)
+		body, err := SendPaginatedGraphQLRequest(ctx, nil, server.URL, 5, nil, request, &RequestOptions{Extract: extractor}, pagination)
+		suite.Nil(err)
+		suite.JSONEq(`{"data": `+data+`, "errors": [{"message": "name is forbidden", "path": ["viewer", "repositories", "nodes", 1, "name"]}], "partial": true}`, string(body))
+	}
+}
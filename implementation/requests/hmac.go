@@ -0,0 +1,223 @@
+package requests
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/blinkops/blink-http/consts"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultHmacTemplate        = "{timestamp}{method}{request_uri}{body}"
+	defaultHmacSignatureHeader = "X-Signature"
+)
+
+var hmacAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha384": sha512.New384,
+	"sha512": sha512.New,
+}
+
+// signingTime is replaced in tests to sign with a fixed time
+var signingTime = time.Now
+
+// handleHmacSignature signs the request with an HMAC over a canonical string built from the template.
+// the template placeholders are {method}, {host}, {path}, {query}, {request_uri}, {timestamp}, {nonce},
+// {key_id}, {body}, {body_sha256}, {body_md5} and {header:Name}, a literal \n in the template is a new line.
+// {body_sha256} is hex encoded while {body_md5} is base64 encoded, the way the Content-MD5 header is
+func handleHmacSignature(connection map[string]string, req *http.Request) error {
+	secret, err := getHmacSecret(connection)
+	if err != nil {
+		return err
+	}
+
+	algorithm := strings.ToLower(strings.ReplaceAll(connection[consts.HmacAlgorithmKey], "-", ""))
+	if algorithm == "" {
+		algorithm = "sha256"
+	}
+	newHash, ok := hmacAlgorithms[algorithm]
+	if !ok {
+		return fmt.Errorf("hmac-signature connection has an unsupported %s: %s", consts.HmacAlgorithmKey, connection[consts.HmacAlgorithmKey])
+	}
+
+	timestamp, err := formatTimestamp(signingTime(), connection[consts.HmacTimestampFormatKey])
+	if err != nil {
+		return err
+	}
+
+	body, err := getRequestBody(req)
+	if err != nil {
+		return err
+	}
+
+	nonce := ""
+	if header := connection[consts.HmacNonceHeaderKey]; header != "" || strings.Contains(connection[consts.HmacTemplateKey], "{nonce}") {
+		nonceBytes := make([]byte, 16)
+		if _, err = rand.Read(nonceBytes); err != nil {
+			return err
+		}
+		nonce = hex.EncodeToString(nonceBytes)
+	}
+
+	// the headers are set first, so the template can refer to them
+	if header := connection[consts.HmacTimestampHeaderKey]; header != "" {
+		req.Header.Set(header, timestamp)
+	}
+	if header := connection[consts.HmacNonceHeaderKey]; header != "" {
+		req.Header.Set(header, nonce)
+	}
+	if header := connection[consts.HmacKeyIdHeaderKey]; header != "" {
+		req.Header.Set(header, connection[consts.HmacKeyIdKey])
+	}
+
+	template := connection[consts.HmacTemplateKey]
+	if template == "" {
+		template = defaultHmacTemplate
+	}
+	canonical, err := buildCanonicalString(template, req, body, map[string]string{
+		"timestamp": timestamp,
+		"nonce":     nonce,
+		"key_id":    connection[consts.HmacKeyIdKey],
+	})
+	if err != nil {
+		return err
+	}
+
+	mac := hmac.New(newHash, secret)
+	mac.Write([]byte(canonical))
+
+	var signature string
+	switch encoding := strings.ToLower(connection[consts.HmacEncodingKey]); encoding {
+	case "", "hex":
+		signature = hex.EncodeToString(mac.Sum(nil))
+	case "base64":
+		signature = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	default:
+		return fmt.Errorf("hmac-signature connection has an unsupported %s: %s, must be hex or base64", consts.HmacEncodingKey, encoding)
+	}
+
+	signatureHeader := connection[consts.HmacSignatureHeaderKey]
+	if signatureHeader == "" {
+		signatureHeader = defaultHmacSignatureHeader
+	}
+	req.Header.Set(signatureHeader, strings.ReplaceAll(connection[consts.HmacSignaturePrefixKey], "{key_id}", connection[consts.HmacKeyIdKey])+signature)
+	return nil
+}
+
+func getHmacSecret(connection map[string]string) ([]byte, error) {
+	secret, ok := connection[consts.HmacSecretKey]
+	if !ok || secret == "" {
+		return nil, fmt.Errorf("hmac-signature connection does not contain a %s attribute", consts.HmacSecretKey)
+	}
+
+	switch encoding := strings.ToLower(connection[consts.HmacSecretEncodingKey]); encoding {
+	case "", "raw":
+		return []byte(secret), nil
+	case "base64":
+		decoded, err := base64.StdEncoding.DecodeString(secret)
+		if err != nil {
+			return nil, fmt.Errorf("hmac-signature secret is not base64 encoded, error: %v", err)
+		}
+		return decoded, nil
+	case "hex":
+		decoded, err := hex.DecodeString(secret)
+		if err != nil {
+			return nil, fmt.Errorf("hmac-signature secret is not hex encoded, error: %v", err)
+		}
+		return decoded, nil
+	default:
+		return nil, fmt.Errorf("hmac-signature connection has an unsupported %s: %s, must be raw, base64 or hex", consts.HmacSecretEncodingKey, encoding)
+	}
+}
+
+func formatTimestamp(now time.Time, format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "", "unix":
+		return strconv.FormatInt(now.Unix(), 10), nil
+	case "unix_ms":
+		return strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 10), nil
+	case "rfc3339":
+		return now.UTC().Format(time.RFC3339), nil
+	case "rfc1123":
+		return now.UTC().Format(http.TimeFormat), nil
+	default:
+		return "", fmt.Errorf("hmac-signature connection has an unsupported %s: %s, must be unix, unix_ms, rfc3339 or rfc1123", consts.HmacTimestampFormatKey, format)
+	}
+}
+
+// getRequestBody reads the body without consuming it, the request is sent with it afterwards
+func getRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody == nil {
+		return nil, errors.New("can't sign the request, its body can't be read more than once")
+	}
+	reader, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(reader)
+}
+
+func buildCanonicalString(template string, req *http.Request, body []byte, values map[string]string) (string, error) {
+	bodySha256 := sha256.Sum256(body)
+	bodyMd5 := md5.Sum(body)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	values["method"] = req.Method
+	values["host"] = host
+	values["path"] = req.URL.EscapedPath()
+	values["query"] = req.URL.RawQuery
+	values["request_uri"] = req.URL.RequestURI()
+	values["body"] = string(body)
+	values["body_sha256"] = hex.EncodeToString(bodySha256[:])
+	values["body_md5"] = base64.StdEncoding.EncodeToString(bodyMd5[:])
+
+	canonical := strings.Builder{}
+	rest := strings.ReplaceAll(template, `\n`, "\n")
+	for {
+		start := strings.Index(rest, "{")
+		if start < 0 {
+			canonical.WriteString(rest)
+			return canonical.String(), nil
+		}
+		end := strings.Index(rest[start:], "}")
+		if end < 0 {
+			return "", fmt.Errorf("hmac-signature template has an unclosed placeholder: %s", rest[start:])
+		}
+		canonical.WriteString(rest[:start])
+
+		placeholder := rest[start+1 : start+end]
+		rest = rest[start+end+1:]
+
+		if strings.HasPrefix(placeholder, "header:") {
+			canonical.WriteString(req.Header.Get(strings.TrimPrefix(placeholder, "header:")))
+			continue
+		}
+		value, ok := values[placeholder]
+		if !ok {
+			return "", fmt.Errorf("hmac-signature template has an unknown placeholder: {%s}", placeholder)
+		}
+		canonical.WriteString(value)
+	}
+}
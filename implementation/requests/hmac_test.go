@@ -0,0 +1,93 @@
+package requests
+
+import (
+	"bytes"
+	"github.com/blinkops/blink-http/consts"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type HmacTestSuite struct {
+	suite.Suite
+}
+
+func TestHmacTestSuite(t *testing.T) {
+	suite.Run(t, new(HmacTestSuite))
+}
+
+func (suite *HmacTestSuite) SetupTest() {
+	signingTime = func() time.Time { return time.Unix(1700000000, 0) }
+}
+
+func (suite *HmacTestSuite) TearDownTest() {
+	signingTime = time.Now
+}
+
+func (suite *HmacTestSuite) newRequest() *http.Request {
+	request, err := http.NewRequest(http.MethodPost, "https://api.exchange.com/v2/orders?limit=5", bytes.NewBufferString(`{"size":1}`))
+	suite.Require().Nil(err)
+	return request
+}
+
+func (suite *HmacTestSuite) TestDefaultSignature() {
+	request := suite.newRequest()
+	err := handleHmacSignature(map[string]string{
+		consts.HmacSecretKey:          "secret",
+		consts.HmacKeyIdKey:           "key-1",
+		consts.HmacKeyIdHeaderKey:     "CB-ACCESS-KEY",
+		consts.HmacTimestampHeaderKey: "CB-ACCESS-TIMESTAMP",
+		consts.HmacSignatureHeaderKey: "CB-ACCESS-SIGN",
+	}, request)
+	suite.Nil(err)
+	suite.Equal("67be3ac5352e37271296046acceef33ea737b3a21982f61dd32d15a8c48b6386", request.Header.Get("CB-ACCESS-SIGN"))
+	suite.Equal("key-1", request.Header.Get("CB-ACCESS-KEY"))
+	suite.Equal("1700000000", request.Header.Get("CB-ACCESS-TIMESTAMP"))
+
+	// the body is still sent after it was signed
+	body, err := ioutil.ReadAll(request.Body)
+	suite.Nil(err)
+	suite.Equal(`{"size":1}`, string(body))
+}
+
+func (suite *HmacTestSuite) TestCustomSignature() {
+	request := suite.newRequest()
+	err := handleHmacSignature(map[string]string{
+		consts.HmacSecretKey:          "YmluYXJ5LXNlY3JldA==",
+		consts.HmacSecretEncodingKey:  "base64",
+		consts.HmacKeyIdKey:           "key-1",
+		consts.HmacAlgorithmKey:       "SHA-512",
+		consts.HmacEncodingKey:        "base64",
+		consts.HmacTemplateKey:        `{method}\n{path}\n{header:X-Timestamp}\n{key_id}\n{body_sha256}`,
+		consts.HmacTimestampHeaderKey: "X-Timestamp",
+		consts.HmacSignaturePrefixKey: "HMAC {key_id}:",
+		consts.HmacSignatureHeaderKey: "Authorization",
+	}, request)
+	suite.Nil(err)
+	suite.Equal("HMAC key-1:tvlzbfEONZd3qEKpic/ONWo0xRp213uPx2DW30LGeQ4Od8cUt8PbiIK5U2KOBBLoYBOqLLFG7wDP7+S8MLFcuw==", request.Header.Get("Authorization"))
+}
+
+func (suite *HmacTestSuite) TestBodyDigests() {
+	canonical, err := buildCanonicalString(`{body_sha256}\n{body_md5}`, suite.newRequest(), []byte(`{"size":1}`), map[string]string{})
+	suite.Nil(err)
+
+	// the sha256 digest is hex encoded and the md5 digest is base64 encoded, like the Content-MD5 header
+	suite.Equal("fe36923e15fedd49a241f76219620a46e7bd8bfb6f17ddeffe18b0299b8b1028\nGG7V0qd+mRecctVczcwysQ==", canonical)
+}
+
+func (suite *HmacTestSuite) TestInvalidConnection() {
+	for _, connection := range []map[string]string{
+		{},
+		{consts.HmacSecretKey: "secret", consts.HmacAlgorithmKey: "sha3"},
+		{consts.HmacSecretKey: "secret", consts.HmacEncodingKey: "base32"},
+		{consts.HmacSecretKey: "not base64!", consts.HmacSecretEncodingKey: "base64"},
+		{consts.HmacSecretKey: "secret", consts.HmacTemplateKey: "{method}{unknown}"},
+		{consts.HmacSecretKey: "secret", consts.HmacTemplateKey: "{method"},
+		{consts.HmacSecretKey: "secret", consts.HmacTimestampFormatKey: "iso"},
+	} {
+		suite.NotNil(handleHmacSignature(connection, suite.newRequest()), connection)
+	}
+}
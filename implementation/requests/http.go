@@ -2,10 +2,13 @@ package requests
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/blinkops/blink-http/consts"
+	"github.com/blinkops/blink-http/implementation/transport"
 	"github.com/blinkops/blink-http/plugins/types"
 	"github.com/blinkops/blink-sdk/plugin"
 	"github.com/blinkops/blink-sdk/plugin/connections"
@@ -15,16 +18,103 @@ import (
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
-func SendRequest(ctx *plugin.ActionContext, plugin types.Plugin, method string, urlString string, timeout int32, headers map[string]string, cookies map[string]string, data []byte) ([]byte, error) {
-	requestBody := bytes.NewBuffer(data)
+type RequestOptions struct {
+	Retry            *RetryPolicy
+	OutputFormat     string
+	MaxRedirects     int
+	DisableRedirects bool
+	Extract          *Extractor
+	// ConvertResponse converts XML or YAML response bodies to JSON, see ConvertResponse
+	ConvertResponse string
+	// Download streams successful response bodies to a file instead of returning them
+	Download *DownloadOptions
+	// Session keeps the cookies between actions, a new cookie jar is used for every request without it
+	Session *Session
+}
 
-	cookieJar, err := cookiejar.New(nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create cookie jar, error: %v", err)
+func SendRequest(ctx *plugin.ActionContext, plugin types.Plugin, method string, urlString string, timeout int32, headers http.Header, cookies map[string]string, data []byte) ([]byte, error) {
+	return SendRequestWithOptions(ctx, plugin, method, urlString, timeout, headers, cookies, data, nil)
+}
+
+func SendRequestWithOptions(ctx *plugin.ActionContext, plugin types.Plugin, method string, urlString string, timeout int32, headers http.Header, cookies map[string]string, data []byte, options *RequestOptions) ([]byte, error) {
+	if options == nil {
+		options = &RequestOptions{}
+	}
+
+	start := time.Now()
+	response, body, err := sendRequest(ctx, plugin, method, urlString, timeout, headers, cookies, data, options)
+	// the body of a download is already the JSON of the downloaded file
+	if body != nil && !isDownloaded(response, options) {
+		body, err = convertResponseBody(response, body, err, options)
+	}
+	return formatResponse(method, response, body, err, time.Since(start), options)
+}
+
+// convertResponseBody converts XML and YAML bodies to JSON when requested and encodes binary bodies
+func convertResponseBody(response *http.Response, body []byte, err error, options *RequestOptions) ([]byte, error) {
+	if options.ConvertResponse != "" && options.ConvertResponse != ConvertResponseNone {
+		converted, convertErr := ConvertResponse(options.ConvertResponse, response.Header.Get("Content-Type"), body)
+		// failed responses are still returned with their original body when it can't be converted
+		if convertErr == nil {
+			body = converted
+		} else if err == nil {
+			err = fmt.Errorf("failed to convert the response to json, error: %v", convertErr)
+		}
+	}
+
+	encoded, encodeErr := encodeBinaryResponse(response, body)
+	if encodeErr != nil {
+		if err == nil {
+			err = encodeErr
+		}
+		return body, err
+	}
+	return encoded, err
+}
+
+// formatResponse applies the output format and extract expression of the options to the response body
+func formatResponse(method string, response *http.Response, body []byte, err error, elapsed time.Duration, options *RequestOptions) ([]byte, error) {
+	// HEAD responses have no body, so the headers are returned instead
+	if method == http.MethodHead && response != nil && body != nil && options.OutputFormat != OutputFormatEnvelope {
+		responseHeaders, marshalErr := json.Marshal(response.Header)
+		if marshalErr != nil {
+			return nil, fmt.Errorf("failed to marshal response headers, error: %v", marshalErr)
+		}
+		body = responseHeaders
+	}
+
+	if body != nil && options.OutputFormat == OutputFormatEnvelope {
+		envelope, marshalErr := json.Marshal(NewResponseEnvelope(response, body, elapsed))
+		if marshalErr != nil {
+			return nil, fmt.Errorf("failed to marshal response envelope, error: %v", marshalErr)
+		}
+		body = envelope
+	}
+
+	// failed responses are returned whole, the expression is meant for the successful response
+	if err != nil || options.Extract == nil {
+		return body, err
+	}
+	return options.Extract.Extract(body)
+}
+
+// sendRequest returns the response along with its validated body, the response body itself is already closed
+func sendRequest(ctx *plugin.ActionContext, plugin types.Plugin, method string, urlString string, timeout int32, headers http.Header, cookies map[string]string, data []byte, options *RequestOptions) (*http.Response, []byte, error) {
+	var cookieJar http.CookieJar
+	if options.Session != nil {
+		cookieJar = options.Session
+	} else {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create cookie jar, error: %v", err)
+		}
+		cookieJar = jar
 	}
 
 	var cookiesList []*http.Cookie
@@ -37,37 +127,134 @@ func SendRequest(ctx *plugin.ActionContext, plugin types.Plugin, method string,
 
 	parsedUrl, err := url.Parse(urlString)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse request url, error: %v", err)
+		return nil, nil, fmt.Errorf("failed to parse request url, error: %v", err)
 	}
 	cookieJar.SetCookies(parsedUrl, cookiesList)
 
+	transportConnection, err := getTransportConnection(ctx.GetAllConnections())
+	if err != nil {
+		return nil, nil, err
+	}
+	httpTransport, err := transport.GetTransport(transportConnection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var authHeaders []string
+
 	// Create new http client with predefined options
 	client := &http.Client{
-		Jar:     cookieJar,
-		Timeout: time.Second * time.Duration(timeout),
+		Jar:       cookieJar,
+		Timeout:   time.Second * time.Duration(timeout),
+		Transport: httpTransport,
+		CheckRedirect: newRedirectPolicy(ctx.GetAllConnections(), plugin, options, func() []string {
+			return authHeaders
+		}),
 	}
 
-	request, err := http.NewRequest(method, urlString, requestBody)
-	if err != nil {
-		return nil, err
+	// the timeout of the client bounds every attempt, the deadline of the context bounds all of them together
+	requestContext := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		requestContext, cancel = context.WithTimeout(requestContext, time.Second*time.Duration(timeout))
+		defer cancel()
 	}
 
-	for name, value := range headers {
-		request.Header.Set(name, value)
-	}
+	// the request is rebuilt for every attempt, so the body can be resent and the auth is renewed
+	newRequest := func() (*http.Request, error) {
+		request, err := http.NewRequestWithContext(requestContext, method, urlString, bytes.NewBuffer(data))
+		if err != nil {
+			return nil, err
+		}
 
-	for connName, connInstance := range ctx.GetAllConnections() {
-		if err = validateURL(connInstance.Data, request.URL, plugin); err != nil {
+		for name, values := range headers {
+			for _, value := range values {
+				request.Header.Add(name, value)
+			}
+		}
+
+		headersBeforeAuth := request.Header.Clone()
+		if err = authenticateRequest(ctx, plugin, request); err != nil {
 			return nil, err
 		}
-		if err = handleAuth(connName, connInstance, request, plugin); err != nil {
+		authHeaders = getAuthHeaders(headersBeforeAuth, request.Header)
+		return request, nil
+	}
+
+	response, err := sendWithRetry(requestContext, client, options.Retry, method, newRequest)
+	if err == nil && response.StatusCode == http.StatusUnauthorized {
+		response, err = resendOnChallenge(ctx, requestContext, client, plugin, options, method, response, newRequest)
+	}
+
+	if err == nil && isDownloaded(response, options) {
+		body, err := options.Download.save(response)
+		return response, body, err
+	}
+	if err == nil && options.Download != nil {
+		// failed downloads return their body like any other response, up to the size cap of the download
+		response.Body = &maxSizeBody{body: response.Body, maxSize: options.Download.getMaxSize()}
+	}
+
+	body, err := CreateResponse(response, err, plugin)
+	return response, body, err
+}
+
+// isDownloaded reports whether the response body is saved to a file, only successful responses are downloaded
+func isDownloaded(response *http.Response, options *RequestOptions) bool {
+	return options.Download != nil && response != nil && response.StatusCode >= http.StatusOK && response.StatusCode < http.StatusMultipleChoices
+}
+
+// resendOnChallenge answers the 401 challenge of connections like digest auth by sending the request once more
+func resendOnChallenge(ctx *plugin.ActionContext, requestContext context.Context, client *http.Client, plugin types.Plugin, options *RequestOptions, method string, response *http.Response, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	pluginWithChallenge, ok := plugin.(types.PluginWithChallenge)
+	if !ok {
+		return response, nil
+	}
+
+	resend := false
+	for _, connInstance := range ctx.GetAllConnections() {
+		handled, err := pluginWithChallenge.HandleChallenge(response, connInstance.Data)
+		if err != nil {
+			_ = response.Body.Close()
 			return nil, err
 		}
+		resend = resend || handled
 	}
+	if !resend {
+		return response, nil
+	}
+
+	_, _ = io.Copy(ioutil.Discard, response.Body)
+	_ = response.Body.Close()
+	return sendWithRetry(requestContext, client, options.Retry, method, newRequest)
+}
 
-	response, err := client.Do(request)
+// getTransportConnection returns the data of the connection that customizes the transport (TLS, proxy), if any
+func getTransportConnection(conns map[string]*connections.ConnectionInstance) (map[string]string, error) {
+	var transportConnection map[string]string
+	for connName, connInstance := range conns {
+		if !transport.HasTransportSettings(connInstance.Data) {
+			continue
+		}
+		if transportConnection != nil {
+			return nil, fmt.Errorf("only one connection can define transport settings, found another one in %s", connName)
+		}
+		transportConnection = connInstance.Data
+	}
+	return transportConnection, nil
+}
 
-	return CreateResponse(response, err, plugin)
+// authenticateRequest makes sure the request url is allowed by every connection and applies their auth
+func authenticateRequest(ctx *plugin.ActionContext, plugin types.Plugin, request *http.Request) error {
+	for connName, connInstance := range ctx.GetAllConnections() {
+		if err := validateURL(connInstance.Data, request.URL, plugin); err != nil {
+			return err
+		}
+		if err := handleAuth(connName, connInstance, request, plugin); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func handleAuth(connName string, connInstance *connections.ConnectionInstance, req *http.Request, plugin types.Plugin) error {
@@ -87,6 +274,10 @@ func handleAuth(connName string, connInstance *connections.ConnectionInstance, r
 		if err := handleApiKeyAuth(connInstance.Data, req); err != nil {
 			return err
 		}
+	case consts.HmacSignatureKey:
+		if err := handleHmacSignature(connInstance.Data, req); err != nil {
+			return err
+		}
 	default:
 		return errors.New("invalid connection type")
 	}
@@ -155,11 +346,28 @@ func validateURL(connection map[string]string, requestedURL *url.URL, plugin typ
 		apiAddressString, ok = connection[consts.ApiAddressKey]; if !ok {
 			// if there's no api address defined, make sure the request is being sent
 			// to the default request url of the connection type
+			if plugin, ok := plugin.(types.PluginWithDefaultRequestUrls); ok {
+				return validateDefaultURLs(plugin.GetDefaultRequestUrls(), requestedURL)
+			}
 			if plugin != nil && plugin.GetDefaultRequestUrl() != "" {
 				apiAddressString = plugin.GetDefaultRequestUrl()
 			}
 		}
 	}
+	return checkRequestedURL(apiAddressString, requestedURL)
+}
+
+// validateDefaultURLs allows the requested url when it matches any of the plugin's default request urls
+func validateDefaultURLs(defaultURLs []string, requestedURL *url.URL) error {
+	for _, defaultURL := range defaultURLs {
+		if checkRequestedURL(defaultURL, requestedURL) == nil {
+			return nil
+		}
+	}
+	return errors.New("the requested url's host/path does not match the host/path defined in the connection. this is not allowed in order to prevent sending credentials to unwanted hosts/paths. the allowed hosts/paths are " + strings.Join(defaultURLs, ", "))
+}
+
+func checkRequestedURL(apiAddressString string, requestedURL *url.URL) error {
 	apiAddressString = strings.Replace(apiAddressString, "www.", "", 1)
 	apiAddress, err := url.Parse(apiAddressString)
 	if err != nil {
@@ -226,24 +434,154 @@ func ValidateResponse(statusCode int, body []byte) ([]byte, error) {
 	return body, nil
 }
 
-func GetHeaders(contentType string, headers string) map[string]string {
-	headerMap := ParseStringToMap(headers, ":")
-	headerMap["Content-Type"] = contentType
+// GetHeaders parses the headers parameter and sets the Content-Type, see ParseHeaders for the supported formats
+func GetHeaders(contentType string, headers string) (http.Header, error) {
+	headerMap, err := ParseHeaders(headers)
+	if err != nil {
+		return nil, err
+	}
+	headerMap.Set("Content-Type", contentType)
+
+	return headerMap, nil
+}
+
+// ParseHeaders parses headers given as "Name: value" lines, a JSON object ({"Name": "value"} or {"Name": ["value1", "value2"]})
+// or a JSON array ([{"name": "Name", "value": "value"}] or ["Name: value"]), a repeated header keeps all of its values
+func ParseHeaders(value string) (http.Header, error) {
+	entries, err := parseEntries(value, ":", "header")
+	if err != nil {
+		return nil, err
+	}
+
+	headers := http.Header{}
+	for _, entry := range entries {
+		headers.Add(entry[0], entry[1])
+	}
+	return headers, nil
+}
+
+// ParseCookies parses cookies given in the same formats as ParseHeaders, with "name=value" lines
+func ParseCookies(value string) (map[string]string, error) {
+	entries, err := parseEntries(value, "=", "cookie")
+	if err != nil {
+		return nil, err
+	}
+
+	cookies := make(map[string]string)
+	for _, entry := range entries {
+		cookies[entry[0]] = entry[1]
+	}
+	return cookies, nil
+}
+
+// parseEntries returns the name and value pairs of the parameter in the order they were given
+func parseEntries(value string, delimiter string, kind string) ([][2]string, error) {
+	value = strings.TrimSpace(value)
+	switch {
+	case value == "":
+		return nil, nil
+	case strings.HasPrefix(value, "{"):
+		return parseJsonObjectEntries(value, kind)
+	case strings.HasPrefix(value, "["):
+		return parseJsonArrayEntries(value, delimiter, kind)
+	}
+
+	var entries [][2]string
+	for i, line := range strings.Split(value, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		entry, err := parseEntryLine(line, delimiter, kind)
+		if err != nil {
+			return nil, fmt.Errorf("%v (line %d)", err, i+1)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
 
-	return headerMap
+func parseEntryLine(line string, delimiter string, kind string) ([2]string, error) {
+	split := strings.SplitN(line, delimiter, 2)
+	if len(split) != 2 || strings.TrimSpace(split[0]) == "" {
+		return [2]string{}, fmt.Errorf("invalid %s %q, expected name%svalue", kind, strings.TrimSpace(line), delimiter)
+	}
+	return [2]string{strings.TrimSpace(split[0]), strings.TrimSpace(split[1])}, nil
 }
 
-func ParseStringToMap(value string, delimiter string) map[string]string {
-	stringMap := make(map[string]string)
+func parseJsonObjectEntries(value string, kind string) ([][2]string, error) {
+	object := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(value), &object); err != nil {
+		return nil, fmt.Errorf("invalid %ss json object, error: %v", kind, err)
+	}
 
-	split := strings.Split(value, "\n")
-	for _, currentParameter := range split {
-		if strings.Contains(currentParameter, delimiter) {
-			currentHeaderSplit := strings.Split(currentParameter, delimiter)
-			parameterKey, parameterValue := currentHeaderSplit[0], strings.TrimSpace(currentHeaderSplit[1])
+	// the names are sorted so the values are always added in the same order
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
-			stringMap[parameterKey] = parameterValue
+	var entries [][2]string
+	for _, name := range names {
+		if strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid %ss json object, a %s name is empty", kind, kind)
 		}
+		values, ok := object[name].([]interface{})
+		if !ok {
+			values = []interface{}{object[name]}
+		}
+		for _, current := range values {
+			entryValue, err := formatEntryValue(current)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value of %s %s, error: %v", kind, name, err)
+			}
+			entries = append(entries, [2]string{name, entryValue})
+		}
+	}
+	return entries, nil
+}
+
+func parseJsonArrayEntries(value string, delimiter string, kind string) ([][2]string, error) {
+	var array []interface{}
+	if err := json.Unmarshal([]byte(value), &array); err != nil {
+		return nil, fmt.Errorf("invalid %ss json array, error: %v", kind, err)
 	}
-	return stringMap
-}
\ No newline at end of file
+
+	var entries [][2]string
+	for i, element := range array {
+		switch element := element.(type) {
+		case string:
+			entry, err := parseEntryLine(element, delimiter, kind)
+			if err != nil {
+				return nil, fmt.Errorf("%v (index %d)", err, i)
+			}
+			entries = append(entries, entry)
+		case map[string]interface{}:
+			name, ok := element["name"].(string)
+			if !ok || strings.TrimSpace(name) == "" {
+				return nil, fmt.Errorf("invalid %s at index %d, expected a name and a value", kind, i)
+			}
+			entryValue, err := formatEntryValue(element["value"])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value of %s %s, error: %v", kind, name, err)
+			}
+			entries = append(entries, [2]string{strings.TrimSpace(name), entryValue})
+		default:
+			return nil, fmt.Errorf("invalid %s at index %d, expected a string or an object", kind, i)
+		}
+	}
+	return entries, nil
+}
+
+func formatEntryValue(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(value), nil
+	default:
+		return "", errors.New("expected a string, a number or a boolean")
+	}
+}
@@ -1,11 +1,22 @@
 package requests
 
 import (
+	"encoding/json"
+	"encoding/pem"
 	"github.com/blinkops/blink-http/consts"
+	"github.com/blinkops/blink-http/implementation/transport"
+	"github.com/blinkops/blink-http/plugins/aws"
 	"github.com/blinkops/blink-http/plugins/datadog"
+	"github.com/blinkops/blink-http/plugins/digest"
 	"github.com/blinkops/blink-http/plugins/github"
 	"github.com/blinkops/blink-http/plugins/jira"
 	"github.com/blinkops/blink-http/plugins/types"
+	"github.com/blinkops/blink-sdk/plugin"
+	"github.com/blinkops/blink-sdk/plugin/connections"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 
@@ -81,6 +92,16 @@ func (suite *HttpTestSuite) TestValidateURL() {
 			requestedURL: "api.datadoghq.com",
 			plugin: datadog.GetNewDatadogPlugin(),
 		},
+		{
+			connection: map[string]string{},
+			requestedURL: "https://ec2.us-east-1.amazonaws.com/?Action=DescribeInstances",
+			plugin: aws.GetNewAwsPlugin(),
+		},
+		{
+			connection: map[string]string{},
+			requestedURL: "https://ec2.cn-north-1.amazonaws.com.cn/?Action=DescribeInstances",
+			plugin: aws.GetNewAwsPlugin(),
+		},
 	} {
 		u, err := url.Parse(goodScenario.requestedURL)
 		suite.Nil(err)
@@ -115,6 +136,21 @@ func (suite *HttpTestSuite) TestValidateURL() {
 			requestedURL: "datadog.com",
 			plugin: datadog.GetNewDatadogPlugin(),
 		},
+		{
+			connection: map[string]string{},
+			requestedURL: "https://amazonaws.com.attacker.io",
+			plugin: aws.GetNewAwsPlugin(),
+		},
+		{
+			connection: map[string]string{},
+			requestedURL: "https://amazonaws.com.cn.attacker.io",
+			plugin: aws.GetNewAwsPlugin(),
+		},
+		{
+			connection: map[string]string{consts.RequestUrlKey: "https://ec2.us-east-1.amazonaws.com"},
+			requestedURL: "https://ec2.cn-north-1.amazonaws.com.cn",
+			plugin: aws.GetNewAwsPlugin(),
+		},
 	} {
 		u, err := url.Parse(badScenario.requestedURL)
 		suite.Nil(err)
@@ -122,3 +158,142 @@ func (suite *HttpTestSuite) TestValidateURL() {
 		suite.NotNil(err)
 	}
 }
+
+func (suite *HttpTestSuite) TestSendRequestMethods() {
+	var receivedMethod, receivedContentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedMethod, receivedContentType = r.Method, r.Header.Get("Content-Type")
+		w.Header().Set("X-Resource-Size", "42")
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	ctx := plugin.NewActionContext(nil, map[string]*connections.ConnectionInstance{
+		consts.ApiTokenKey: {Data: map[string]string{consts.RequestUrlKey: server.URL}},
+	})
+
+	body, err := SendRequest(ctx, nil, "PROPFIND", server.URL, 5, http.Header{"Depth": {"1"}}, nil, nil)
+	suite.Nil(err)
+	suite.Equal("PROPFIND", receivedMethod)
+	suite.Equal(`{"ok":true}`, string(body))
+
+	body, err = SendRequest(ctx, nil, http.MethodHead, server.URL, 5, nil, nil, nil)
+	suite.Nil(err)
+	suite.Equal(http.MethodHead, receivedMethod)
+	suite.Equal("", receivedContentType)
+
+	var headers map[string][]string
+	suite.Nil(json.Unmarshal(body, &headers))
+	suite.Equal([]string{"42"}, headers["X-Resource-Size"])
+}
+
+func (suite *HttpTestSuite) TestSendRequestWithChallenge() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.Header().Set("WWW-Authenticate", `Digest realm="test", qop="auth", nonce="abc"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("authenticated"))
+	}))
+	defer server.Close()
+
+	ctx := plugin.NewActionContext(nil, map[string]*connections.ConnectionInstance{
+		"digest-auth": {Data: map[string]string{
+			consts.RequestUrlKey:     server.URL,
+			consts.BasicAuthUsername: "admin",
+			consts.BasicAuthPassword: "secret",
+		}},
+	})
+
+	body, err := SendRequest(ctx, digest.GetNewDigestPlugin(), http.MethodGet, server.URL, 5, nil, nil, nil)
+	suite.Nil(err)
+	suite.Equal("authenticated", string(body))
+}
+
+func (suite *HttpTestSuite) TestParseHeaders() {
+	expected := http.Header{
+		"Referer":    {"https://example.com:8443/path"},
+		"Accept":     {"application/json", "text/plain"},
+		"X-Retry-In": {"10"},
+	}
+	for _, headers := range []string{
+		"Referer: https://example.com:8443/path\nAccept: application/json\n\nAccept: text/plain\nX-Retry-In:10",
+		`{"Referer": "https://example.com:8443/path", "Accept": ["application/json", "text/plain"], "X-Retry-In": 10}`,
+		`[{"name": "Referer", "value": "https://example.com:8443/path"}, "Accept: application/json", "Accept: text/plain", {"name": "X-Retry-In", "value": 10}]`,
+	} {
+		parsed, err := ParseHeaders(headers)
+		suite.Nil(err, headers)
+		suite.Equal(expected, parsed, headers)
+	}
+
+	for _, headers := range []string{
+		"Accept: application/json\nno delimiter",
+		": value",
+		`{"Accept": {"nested": true}}`,
+		`[{"value": "no name"}]`,
+		`[42]`,
+		`{"Accept": "application/json"`,
+	} {
+		_, err := ParseHeaders(headers)
+		suite.NotNil(err, headers)
+	}
+}
+
+func (suite *HttpTestSuite) TestParseCookies() {
+	for _, cookies := range []string{
+		"session=eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0=\ntheme = dark",
+		`{"session": "eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0=", "theme": "dark"}`,
+		`["session=eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0=", {"name": "theme", "value": "dark"}]`,
+	} {
+		parsed, err := ParseCookies(cookies)
+		suite.Nil(err, cookies)
+		suite.Equal(map[string]string{"session": "eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0=", "theme": "dark"}, parsed, cookies)
+	}
+
+	_, err := ParseCookies("session")
+	suite.NotNil(err)
+}
+
+func benchmarkRepeatedRequests(b *testing.B, getTransport func(conn map[string]string) (*http.Transport, error)) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	connection := map[string]string{
+		consts.TlsCaCertKey: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})),
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		httpTransport, err := getTransport(connection)
+		if err != nil {
+			b.Fatal(err)
+		}
+		client := &http.Client{Transport: httpTransport}
+		response, err := client.Get(server.URL)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_, _ = io.Copy(ioutil.Discard, response.Body)
+		_ = response.Body.Close()
+	}
+}
+
+// BenchmarkNewTransportPerRequest creates a transport for every request, which means a new
+// TCP connection and TLS handshake every time, the way requests were sent before the registry
+func BenchmarkNewTransportPerRequest(b *testing.B) {
+	benchmarkRepeatedRequests(b, func(conn map[string]string) (*http.Transport, error) {
+		httpTransport, err := transport.NewTransport(conn)
+		if err == nil {
+			b.Cleanup(httpTransport.CloseIdleConnections)
+		}
+		return httpTransport, err
+	})
+}
+
+// BenchmarkSharedTransport reuses the pooled connection of the shared transport
+func BenchmarkSharedTransport(b *testing.B) {
+	benchmarkRepeatedRequests(b, transport.GetTransport)
+}
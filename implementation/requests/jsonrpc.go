@@ -0,0 +1,199 @@
+package requests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/blinkops/blink-http/plugins/types"
+	"github.com/blinkops/blink-sdk/plugin"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const jsonRpcVersion = "2.0"
+
+// lastJsonRpcId makes the generated request ids unique across actions
+var lastJsonRpcId uint64
+
+type JsonRpcRequest struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	// Id is nil for notifications, which the server doesn't respond to
+	Id *uint64 `json:"id,omitempty"`
+}
+
+type JsonRpcError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+type jsonRpcResponse struct {
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *JsonRpcError   `json:"error,omitempty"`
+	Id     json.RawMessage `json:"id"`
+}
+
+func (e *JsonRpcError) Error() string {
+	return fmt.Sprintf("json-rpc error %d: %s", e.Code, e.Message)
+}
+
+// NewJsonRpcRequest returns a request with a generated id, the params must be a JSON array (positional) or object (named)
+func NewJsonRpcRequest(method string, params string, notification bool) (*JsonRpcRequest, error) {
+	if method == "" {
+		return nil, errors.New("no json-rpc method provided")
+	}
+
+	request := &JsonRpcRequest{JsonRpc: jsonRpcVersion, Method: method}
+	if !notification {
+		id := atomic.AddUint64(&lastJsonRpcId, 1)
+		request.Id = &id
+	}
+
+	params = strings.TrimSpace(params)
+	if params == "" {
+		return request, nil
+	}
+	if !strings.HasPrefix(params, "[") && !strings.HasPrefix(params, "{") || !json.Valid([]byte(params)) {
+		return nil, fmt.Errorf("json-rpc params of %s must be a JSON array or object", method)
+	}
+	request.Params = json.RawMessage(params)
+	return request, nil
+}
+
+// ParseJsonRpcBatch parses a JSON array of {"method": ..., "params": ..., "notification": false} calls
+func ParseJsonRpcBatch(batch string) ([]*JsonRpcRequest, error) {
+	var calls []struct {
+		Method       string          `json:"method"`
+		Params       json.RawMessage `json:"params"`
+		Notification bool            `json:"notification"`
+	}
+	if err := json.Unmarshal([]byte(batch), &calls); err != nil {
+		return nil, fmt.Errorf("json-rpc batch must be a JSON array of calls, error: %v", err)
+	}
+	if len(calls) == 0 {
+		return nil, errors.New("json-rpc batch is empty")
+	}
+
+	batchRequests := make([]*JsonRpcRequest, 0, len(calls))
+	for i, call := range calls {
+		request, err := NewJsonRpcRequest(call.Method, string(call.Params), call.Notification)
+		if err != nil {
+			return nil, fmt.Errorf("invalid json-rpc call at index %d, error: %v", i, err)
+		}
+		batchRequests = append(batchRequests, request)
+	}
+	return batchRequests, nil
+}
+
+// SendJsonRpcRequest sends a single request and returns its result, a response with an error object fails with its code and message
+func SendJsonRpcRequest(ctx *plugin.ActionContext, plugin types.Plugin, urlString string, timeout int32, headers http.Header, request *JsonRpcRequest, options *RequestOptions) ([]byte, error) {
+	return sendJsonRpc(ctx, plugin, urlString, timeout, headers, request, options, func(body []byte) ([]byte, error) {
+		if request.Id == nil {
+			return body, nil
+		}
+
+		response := jsonRpcResponse{}
+		if err := json.Unmarshal(body, &response); err != nil {
+			return body, fmt.Errorf("invalid json-rpc response, error: %v", err)
+		}
+		if response.Error != nil {
+			return body, response.Error
+		}
+		return response.Result, nil
+	})
+}
+
+// SendJsonRpcBatch sends the requests in a single batch and returns their results in the order of the requests,
+// notifications have no result. when any of the calls fails the responses are returned in the order of the requests
+func SendJsonRpcBatch(ctx *plugin.ActionContext, plugin types.Plugin, urlString string, timeout int32, headers http.Header, batch []*JsonRpcRequest, options *RequestOptions) ([]byte, error) {
+	return sendJsonRpc(ctx, plugin, urlString, timeout, headers, batch, options, func(body []byte) ([]byte, error) {
+		return matchJsonRpcResponses(batch, body)
+	})
+}
+
+func sendJsonRpc(ctx *plugin.ActionContext, plugin types.Plugin, urlString string, timeout int32, headers http.Header, payload interface{}, options *RequestOptions, handleBody func([]byte) ([]byte, error)) ([]byte, error) {
+	if options == nil {
+		options = &RequestOptions{}
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if headers == nil {
+		headers = http.Header{}
+	}
+	headers.Set("Content-Type", "application/json")
+
+	start := time.Now()
+	response, body, err := sendRequest(ctx, plugin, http.MethodPost, urlString, timeout, headers, nil, data, options)
+	if err == nil {
+		body, err = handleBody(body)
+	}
+	return formatResponse(http.MethodPost, response, body, err, time.Since(start), options)
+}
+
+func matchJsonRpcResponses(batch []*JsonRpcRequest, body []byte) ([]byte, error) {
+	// a batch of notifications has no response
+	if len(bytes.TrimSpace(body)) == 0 {
+		return []byte("[]"), nil
+	}
+
+	var responses []jsonRpcResponse
+	if err := json.Unmarshal(body, &responses); err != nil {
+		// servers that can't parse the batch respond with a single error
+		single := jsonRpcResponse{}
+		if json.Unmarshal(body, &single) == nil && single.Error != nil {
+			return body, single.Error
+		}
+		return body, fmt.Errorf("invalid json-rpc batch response, error: %v", err)
+	}
+
+	byId := map[string]jsonRpcResponse{}
+	for _, response := range responses {
+		byId[getJsonRpcId(response.Id)] = response
+	}
+
+	results := []json.RawMessage{}
+	ordered := []jsonRpcResponse{}
+	var failures []string
+	for _, request := range batch {
+		if request.Id == nil {
+			continue
+		}
+		id := fmt.Sprint(*request.Id)
+		response, ok := byId[id]
+		if !ok {
+			failures = append(failures, fmt.Sprintf("%s (id %s): no response", request.Method, id))
+			response = jsonRpcResponse{Id: json.RawMessage(id)}
+		} else if response.Error != nil {
+			failures = append(failures, fmt.Sprintf("%s (id %s): %v", request.Method, id, response.Error))
+		}
+		results = append(results, response.Result)
+		ordered = append(ordered, response)
+	}
+
+	if len(failures) > 0 {
+		failed, err := json.Marshal(ordered)
+		if err != nil {
+			return nil, err
+		}
+		return failed, errors.New(strings.Join(failures, "; "))
+	}
+	return json.Marshal(results)
+}
+
+// getJsonRpcId returns the id without its quotes, some servers respond with the numeric ids as strings
+func getJsonRpcId(id json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(id, &text); err == nil {
+		return text
+	}
+	return string(bytes.TrimSpace(id))
+}
@@ -0,0 +1,147 @@
+package requests
+
+import (
+	"encoding/json"
+	"github.com/blinkops/blink-http/consts"
+	"github.com/blinkops/blink-sdk/plugin"
+	"github.com/blinkops/blink-sdk/plugin/connections"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type JsonRpcTestSuite struct {
+	suite.Suite
+	ctx    *plugin.ActionContext
+	server *httptest.Server
+}
+
+func TestJsonRpcTestSuite(t *testing.T) {
+	suite.Run(t, new(JsonRpcTestSuite))
+}
+
+type testJsonRpcCall struct {
+	JsonRpc string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	Id      json.RawMessage `json:"id"`
+}
+
+// respond answers eth_blockNumber, echoes the params of echo and fails any other method
+func (suite *JsonRpcTestSuite) respond(call testJsonRpcCall) map[string]interface{} {
+	suite.Equal("2.0", call.JsonRpc)
+	switch call.Method {
+	case "eth_blockNumber":
+		return map[string]interface{}{"jsonrpc": "2.0", "id": call.Id, "result": "0x10"}
+	case "echo":
+		return map[string]interface{}{"jsonrpc": "2.0", "id": call.Id, "result": call.Params}
+	default:
+		return map[string]interface{}{"jsonrpc": "2.0", "id": call.Id, "error": map[string]interface{}{"code": -32601, "message": "Method not found"}}
+	}
+}
+
+func (suite *JsonRpcTestSuite) SetupTest() {
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suite.Equal("application/json", r.Header.Get("Content-Type"))
+
+		var raw json.RawMessage
+		suite.Require().Nil(json.NewDecoder(r.Body).Decode(&raw))
+		if raw[0] != '[' {
+			call := testJsonRpcCall{}
+			suite.Nil(json.Unmarshal(raw, &call))
+			suite.Nil(json.NewEncoder(w).Encode(suite.respond(call)))
+			return
+		}
+
+		var calls []testJsonRpcCall
+		suite.Nil(json.Unmarshal(raw, &calls))
+		// the responses of a batch may come in any order
+		responses := []map[string]interface{}{}
+		for i := len(calls) - 1; i >= 0; i-- {
+			if calls[i].Id != nil {
+				responses = append(responses, suite.respond(calls[i]))
+			}
+		}
+		if len(responses) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		suite.Nil(json.NewEncoder(w).Encode(responses))
+	}))
+	suite.ctx = plugin.NewActionContext(nil, map[string]*connections.ConnectionInstance{
+		consts.ApiTokenKey: {Data: map[string]string{consts.RequestUrlKey: suite.server.URL}},
+	})
+}
+
+func (suite *JsonRpcTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func (suite *JsonRpcTestSuite) TestNewJsonRpcRequest() {
+	first, err := NewJsonRpcRequest("echo", `[1, 2]`, false)
+	suite.Nil(err)
+	second, err := NewJsonRpcRequest("echo", `{"a": 1}`, false)
+	suite.Nil(err)
+	suite.NotEqual(*first.Id, *second.Id)
+
+	notification, err := NewJsonRpcRequest("notify", "", true)
+	suite.Nil(err)
+	body, err := json.Marshal(notification)
+	suite.Nil(err)
+	suite.JSONEq(`{"jsonrpc": "2.0", "method": "notify"}`, string(body))
+
+	for _, params := range []string{`"text"`, `42`, `[1, 2`} {
+		_, err = NewJsonRpcRequest("echo", params, false)
+		suite.NotNil(err, params)
+	}
+	_, err = NewJsonRpcRequest("", "", false)
+	suite.NotNil(err)
+}
+
+func (suite *JsonRpcTestSuite) TestSendJsonRpcRequest() {
+	request, err := NewJsonRpcRequest("echo", `{"name": "blink"}`, false)
+	suite.Require().Nil(err)
+	body, err := SendJsonRpcRequest(suite.ctx, nil, suite.server.URL, 5, nil, request, nil)
+	suite.Nil(err)
+	suite.JSONEq(`{"name": "blink"}`, string(body))
+
+	request, err = NewJsonRpcRequest("unknown", "", false)
+	suite.Require().Nil(err)
+	body, err = SendJsonRpcRequest(suite.ctx, nil, suite.server.URL, 5, nil, request, nil)
+	suite.EqualError(err, "json-rpc error -32601: Method not found")
+	suite.Contains(string(body), `"code":-32601`)
+}
+
+func (suite *JsonRpcTestSuite) TestSendJsonRpcBatch() {
+	batch, err := ParseJsonRpcBatch(`[{"method": "eth_blockNumber"}, {"method": "notify", "notification": true}, {"method": "echo", "params": [1]}]`)
+	suite.Require().Nil(err)
+	body, err := SendJsonRpcBatch(suite.ctx, nil, suite.server.URL, 5, nil, batch, nil)
+	suite.Nil(err)
+	suite.JSONEq(`["0x10", [1]]`, string(body))
+
+	batch, err = ParseJsonRpcBatch(`[{"method": "echo", "params": [1]}, {"method": "unknown"}]`)
+	suite.Require().Nil(err)
+	body, err = SendJsonRpcBatch(suite.ctx, nil, suite.server.URL, 5, nil, batch, nil)
+	suite.NotNil(err)
+	suite.Contains(err.Error(), "unknown (id ")
+	suite.Contains(err.Error(), "json-rpc error -32601: Method not found")
+
+	var responses []map[string]interface{}
+	suite.Nil(json.Unmarshal(body, &responses))
+	suite.Require().Len(responses, 2)
+	suite.Equal([]interface{}{float64(1)}, responses[0]["result"])
+	suite.NotNil(responses[1]["error"])
+
+	batch, err = ParseJsonRpcBatch(`[{"method": "notify", "notification": true}]`)
+	suite.Require().Nil(err)
+	body, err = SendJsonRpcBatch(suite.ctx, nil, suite.server.URL, 5, nil, batch, nil)
+	suite.Nil(err)
+	suite.Equal("[]", string(body))
+
+	for _, invalid := range []string{`[]`, `{"method": "echo"}`, `[{"params": [1]}]`} {
+		_, err = ParseJsonRpcBatch(invalid)
+		suite.NotNil(err, invalid)
+	}
+}
@@ -0,0 +1,116 @@
+package requests
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+)
+
+// MultipartField is either a plain form field (Value) or a file, taken from
+// base64 encoded Content or read from a local Path. A field with a FileName is
+// always a file, so an empty Content uploads an empty file.
+type MultipartField struct {
+	Name        string `json:"name"`
+	Value       string `json:"value"`
+	FileName    string `json:"filename"`
+	Content     string `json:"content"`
+	Path        string `json:"path"`
+	ContentType string `json:"contentType"`
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// BuildMultipartBody builds a multipart/form-data body out of a json array of fields
+// and returns it along with its Content-Type header, which includes the boundary
+func BuildMultipartBody(fieldsJson string) ([]byte, string, error) {
+	var fields []MultipartField
+	if err := json.Unmarshal([]byte(fieldsJson), &fields); err != nil {
+		return nil, "", fmt.Errorf("multipart fields must be a json array of fields, error: %v", err)
+	}
+	if len(fields) == 0 {
+		return nil, "", errors.New("no multipart fields provided")
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	for i, field := range fields {
+		if field.Name == "" {
+			return nil, "", fmt.Errorf("multipart field %d has no name", i)
+		}
+
+		if field.FileName == "" && field.Content == "" && field.Path == "" {
+			if err := writer.WriteField(field.Name, field.Value); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+
+		if field.Content != "" && field.Path != "" {
+			return nil, "", fmt.Errorf("multipart field %s has both content and path, only one of them can be provided", field.Name)
+		}
+
+		content, fileName, err := getFileContent(field)
+		if err != nil {
+			return nil, "", err
+		}
+
+		contentType := field.ContentType
+		if contentType == "" {
+			contentType = mime.TypeByExtension(filepath.Ext(fileName))
+		}
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, quoteEscaper.Replace(field.Name), quoteEscaper.Replace(fileName)))
+		header.Set("Content-Type", contentType)
+
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err = part.Write(content); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+
+	return body.Bytes(), writer.FormDataContentType(), nil
+}
+
+func getFileContent(field MultipartField) ([]byte, string, error) {
+	fileName := field.FileName
+
+	if field.Path != "" {
+		content, err := ioutil.ReadFile(field.Path)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read file for multipart field %s, error: %v", field.Name, err)
+		}
+		if fileName == "" {
+			fileName = filepath.Base(field.Path)
+		}
+		return content, fileName, nil
+	}
+
+	content, err := base64.StdEncoding.DecodeString(field.Content)
+	if err != nil {
+		return nil, "", fmt.Errorf("content of multipart field %s must be base64 encoded, error: %v", field.Name, err)
+	}
+	if fileName == "" {
+		fileName = field.Name
+	}
+	return content, fileName, nil
+}
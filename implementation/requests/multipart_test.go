@@ -0,0 +1,89 @@
+package requests
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type MultipartTestSuite struct {
+	suite.Suite
+}
+
+func TestMultipartTestSuite(t *testing.T) {
+	suite.Run(t, new(MultipartTestSuite))
+}
+
+func (suite *MultipartTestSuite) TestBuildMultipartBody() {
+	dir, err := ioutil.TempDir("", "multipart")
+	suite.Require().Nil(err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	filePath := filepath.Join(dir, "sample.txt")
+	suite.Require().Nil(ioutil.WriteFile(filePath, []byte("from disk"), 0644))
+
+	fields := `[
+		{"name": "channels", "value": "C123"},
+		{"name": "file", "filename": "report.pdf", "content": "aGVsbG8="},
+		{"name": "sample", "path": "` + filePath + `", "contentType": "text/plain"},
+		{"name": "empty", "filename": "empty.txt", "content": ""}
+	]`
+
+	body, contentType, err := BuildMultipartBody(fields)
+	suite.Nil(err)
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	suite.Nil(err)
+	suite.Equal("multipart/form-data", mediaType)
+	suite.NotEmpty(params["boundary"])
+
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+
+	part, err := reader.NextPart()
+	suite.Nil(err)
+	suite.Equal("channels", part.FormName())
+	suite.Equal("", part.FileName())
+	content, _ := ioutil.ReadAll(part)
+	suite.Equal("C123", string(content))
+
+	part, err = reader.NextPart()
+	suite.Nil(err)
+	suite.Equal("file", part.FormName())
+	suite.Equal("report.pdf", part.FileName())
+	suite.Equal("application/pdf", part.Header.Get("Content-Type"))
+	content, _ = ioutil.ReadAll(part)
+	suite.Equal("hello", string(content))
+
+	part, err = reader.NextPart()
+	suite.Nil(err)
+	suite.Equal("sample", part.FormName())
+	suite.Equal("sample.txt", part.FileName())
+	suite.Equal("text/plain", part.Header.Get("Content-Type"))
+	content, _ = ioutil.ReadAll(part)
+	suite.Equal("from disk", string(content))
+
+	part, err = reader.NextPart()
+	suite.Nil(err)
+	suite.Equal("empty", part.FormName())
+	suite.Equal("empty.txt", part.FileName())
+	content, _ = ioutil.ReadAll(part)
+	suite.Equal("", string(content))
+
+	for _, badFields := range []string{
+		`not json`,
+		`[]`,
+		`[{"value": "no name"}]`,
+		`[{"name": "file", "content": "not base64!"}]`,
+		`[{"name": "file", "content": "aGVsbG8=", "path": "` + filePath + `"}]`,
+		`[{"name": "file", "path": "` + filepath.Join(dir, "missing") + `"}]`,
+	} {
+		_, _, err = BuildMultipartBody(badFields)
+		suite.NotNil(err)
+	}
+}
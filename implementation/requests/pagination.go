@@ -0,0 +1,347 @@
+package requests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/blinkops/blink-http/consts"
+	"github.com/blinkops/blink-http/plugins/types"
+	"github.com/blinkops/blink-sdk/plugin"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	PaginationNone = "none"
+	PaginationAuto = "auto"
+
+	defaultPaginationMaxPages = 10
+)
+
+type PaginationOptions struct {
+	types.Pagination
+	MaxPages int
+	MaxItems int
+}
+
+// pager keeps the position of the offset and page strategies between pages
+type pager struct {
+	options *PaginationOptions
+	offset  int
+	page    int
+}
+
+// ParsePagination returns nil when pagination isn't requested. the auto strategy, or choosing
+// the same strategy the integration declares, starts from the integration's defaults.
+func ParsePagination(parameters map[string]string, plugin types.Plugin) (*PaginationOptions, error) {
+	strategy := parameters[consts.PaginationKey]
+	if strategy == "" || strategy == PaginationNone {
+		return nil, nil
+	}
+
+	options := &PaginationOptions{MaxPages: defaultPaginationMaxPages}
+	if pluginWithPagination, ok := plugin.(types.PluginWithPagination); ok {
+		defaults := pluginWithPagination.GetPagination()
+		if strategy == PaginationAuto || strategy == defaults.Strategy {
+			options.Pagination = defaults
+		}
+	}
+
+	if strategy == PaginationAuto {
+		if options.Strategy == "" {
+			return nil, errors.New("the connection does not declare a default pagination strategy, please choose one explicitly")
+		}
+	} else {
+		options.Strategy = strategy
+	}
+
+	for key, field := range map[string]*string{
+		consts.PaginationItemsPathKey:   &options.ItemsPath,
+		consts.PaginationCursorPathKey:  &options.CursorPath,
+		consts.PaginationCursorParamKey: &options.CursorParam,
+		consts.PaginationOffsetParamKey: &options.OffsetParam,
+		consts.PaginationPageParamKey:   &options.PageParam,
+		consts.PaginationLimitParamKey:  &options.LimitParam,
+	} {
+		if value := strings.TrimSpace(parameters[key]); value != "" {
+			*field = value
+		}
+	}
+
+	for key, field := range map[string]*int{
+		consts.PaginationPageSizeKey: &options.PageSize,
+		consts.PaginationMaxPagesKey: &options.MaxPages,
+		consts.PaginationMaxItemsKey: &options.MaxItems,
+	} {
+		value := strings.TrimSpace(parameters[key])
+		if value == "" {
+			continue
+		}
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 0 {
+			return nil, fmt.Errorf("invalid %s: %s, must be a non negative integer", key, value)
+		}
+		*field = number
+	}
+
+	switch options.Strategy {
+	case types.PaginationLink:
+	case types.PaginationCursor:
+		if options.CursorPath == "" || options.CursorParam == "" {
+			return nil, fmt.Errorf("cursor pagination requires both %s and %s", consts.PaginationCursorPathKey, consts.PaginationCursorParamKey)
+		}
+	case types.PaginationOffset:
+		if options.OffsetParam == "" {
+			return nil, fmt.Errorf("offset pagination requires %s", consts.PaginationOffsetParamKey)
+		}
+	case types.PaginationPage:
+		if options.PageParam == "" {
+			return nil, fmt.Errorf("page pagination requires %s", consts.PaginationPageParamKey)
+		}
+	default:
+		return nil, fmt.Errorf("invalid pagination strategy: %s", options.Strategy)
+	}
+
+	if options.MaxPages == 0 {
+		return nil, fmt.Errorf("%s must be greater than 0", consts.PaginationMaxPagesKey)
+	}
+
+	return options, nil
+}
+
+// SendPaginatedRequest follows the pages of a GET request and returns the items of all the pages as a single json array
+func SendPaginatedRequest(ctx *plugin.ActionContext, plugin types.Plugin, urlString string, timeout int32, headers http.Header, cookies map[string]string, options *RequestOptions, pagination *PaginationOptions) ([]byte, error) {
+	if options == nil {
+		options = &RequestOptions{}
+	}
+
+	pageUrl, err := url.Parse(urlString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse request url, error: %v", err)
+	}
+
+	pages, err := newPager(pagination, pageUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []interface{}{}
+	for pageCount := 1; pageCount <= pagination.MaxPages; pageCount++ {
+		response, body, err := sendRequest(ctx, plugin, http.MethodGet, pageUrl.String(), timeout, headers, cookies, nil, options)
+		if err != nil {
+			return body, err
+		}
+
+		var decoded interface{}
+		if err = json.Unmarshal(body, &decoded); err != nil {
+			return body, fmt.Errorf("page %d is not a valid json, error: %v", pageCount, err)
+		}
+
+		pageItems, err := getPageItems(decoded, pagination.ItemsPath)
+		if err != nil {
+			return body, err
+		}
+		items = append(items, pageItems...)
+
+		if pagination.MaxItems > 0 && len(items) >= pagination.MaxItems {
+			items = items[:pagination.MaxItems]
+			break
+		}
+
+		pageUrl, err = pages.next(pageUrl, response, decoded, len(pageItems))
+		if err != nil {
+			return body, err
+		}
+		if pageUrl == nil {
+			break
+		}
+	}
+
+	merged, err := json.Marshal(items)
+	if err != nil || options.Extract == nil {
+		return merged, err
+	}
+	return options.Extract.Extract(merged)
+}
+
+func newPager(options *PaginationOptions, pageUrl *url.URL) (*pager, error) {
+	p := &pager{options: options, page: 1}
+	query := pageUrl.Query()
+
+	if options.LimitParam != "" && options.PageSize > 0 {
+		query.Set(options.LimitParam, strconv.Itoa(options.PageSize))
+	}
+
+	var err error
+	switch options.Strategy {
+	case types.PaginationOffset:
+		if value := query.Get(options.OffsetParam); value != "" {
+			if p.offset, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("invalid %s in the request url: %s", options.OffsetParam, value)
+			}
+		}
+		query.Set(options.OffsetParam, strconv.Itoa(p.offset))
+	case types.PaginationPage:
+		if value := query.Get(options.PageParam); value != "" {
+			if p.page, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("invalid %s in the request url: %s", options.PageParam, value)
+			}
+		}
+		query.Set(options.PageParam, strconv.Itoa(p.page))
+	}
+
+	pageUrl.RawQuery = query.Encode()
+	return p, nil
+}
+
+// next returns the url of the next page, or nil when there are no more pages
+func (p *pager) next(pageUrl *url.URL, response *http.Response, body interface{}, pageItemsCount int) (*url.URL, error) {
+	switch p.options.Strategy {
+	case types.PaginationLink:
+		next := getNextLink(response.Header.Values("Link"))
+		if next == "" {
+			return nil, nil
+		}
+		nextUrl, err := url.Parse(next)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the next page link: %s, error: %v", next, err)
+		}
+		return pageUrl.ResolveReference(nextUrl), nil
+	case types.PaginationCursor:
+		cursor, ok := getJsonPath(body, p.options.CursorPath)
+		if !ok || cursor == nil || cursor == "" || cursor == false {
+			return nil, nil
+		}
+		if number, ok := cursor.(float64); ok {
+			return withQueryParam(pageUrl, p.options.CursorParam, strconv.FormatFloat(number, 'f', -1, 64)), nil
+		}
+		return withQueryParam(pageUrl, p.options.CursorParam, fmt.Sprint(cursor)), nil
+	case types.PaginationOffset:
+		if p.isLastPage(pageItemsCount) {
+			return nil, nil
+		}
+		p.offset += pageItemsCount
+		return withQueryParam(pageUrl, p.options.OffsetParam, strconv.Itoa(p.offset)), nil
+	case types.PaginationPage:
+		if p.isLastPage(pageItemsCount) {
+			return nil, nil
+		}
+		p.page++
+		return withQueryParam(pageUrl, p.options.PageParam, strconv.Itoa(p.page)), nil
+	}
+	return nil, fmt.Errorf("invalid pagination strategy: %s", p.options.Strategy)
+}
+
+func (p *pager) isLastPage(pageItemsCount int) bool {
+	return pageItemsCount == 0 || (p.options.PageSize > 0 && pageItemsCount < p.options.PageSize)
+}
+
+func withQueryParam(pageUrl *url.URL, name string, value string) *url.URL {
+	nextUrl := *pageUrl
+	query := nextUrl.Query()
+	query.Set(name, value)
+	nextUrl.RawQuery = query.Encode()
+	return &nextUrl
+}
+
+// getNextLink returns the rel="next" link of RFC 8288 Link headers
+// for example: <https://api.github.com/user/repos?page=2>; rel="next", <https://api.github.com/user/repos?page=5>; rel="last"
+func getNextLink(headers []string) string {
+	for _, header := range headers {
+		for header != "" {
+			start := strings.Index(header, "<")
+			end := strings.Index(header, ">")
+			if start < 0 || end < start {
+				break
+			}
+			link := header[start+1 : end]
+			header = header[end+1:]
+
+			params := header
+			if nextLink := strings.Index(header, "<"); nextLink >= 0 {
+				params = header[:nextLink]
+			}
+			params = strings.TrimRight(strings.TrimSpace(params), ",")
+			for _, param := range strings.Split(params, ";") {
+				name, value := splitPair(param, "=")
+				if !strings.EqualFold(name, "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(value, `"`)) {
+					if strings.EqualFold(rel, "next") {
+						return link
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func splitPair(value string, delimiter string) (string, string) {
+	split := strings.SplitN(value, delimiter, 2)
+	if len(split) < 2 {
+		return strings.TrimSpace(split[0]), ""
+	}
+	return strings.TrimSpace(split[0]), strings.TrimSpace(split[1])
+}
+
+func getPageItems(body interface{}, itemsPath string) ([]interface{}, error) {
+	if itemsPath != "" {
+		value, ok := getJsonPath(body, itemsPath)
+		if !ok || value == nil {
+			return []interface{}{}, nil
+		}
+		items, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("the value at %s is not an array", itemsPath)
+		}
+		return items, nil
+	}
+
+	switch value := body.(type) {
+	case []interface{}:
+		return value, nil
+	case map[string]interface{}:
+		var items []interface{}
+		found := 0
+		for _, field := range value {
+			if array, ok := field.([]interface{}); ok {
+				items = array
+				found++
+			}
+		}
+		if found == 1 {
+			return items, nil
+		}
+	}
+	return nil, fmt.Errorf("could not find the items in the response, please set %s", consts.PaginationItemsPathKey)
+}
+
+// getJsonPath returns the value at a dot separated path, array elements are accessed by their index (items.0.id)
+func getJsonPath(value interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return value, true
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch current := value.(type) {
+		case map[string]interface{}:
+			next, ok := current[key]
+			if !ok {
+				return nil, false
+			}
+			value = next
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(current) {
+				return nil, false
+			}
+			value = current[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
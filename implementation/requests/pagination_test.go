@@ -0,0 +1,144 @@
+package requests
+
+import (
+	"github.com/blinkops/blink-http/consts"
+	"github.com/blinkops/blink-http/plugins/github"
+	"github.com/blinkops/blink-http/plugins/slack"
+	"github.com/blinkops/blink-http/plugins/types"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PaginationTestSuite struct {
+	suite.Suite
+}
+
+func TestPaginationTestSuite(t *testing.T) {
+	suite.Run(t, new(PaginationTestSuite))
+}
+
+func (suite *PaginationTestSuite) TestParsePagination() {
+	pagination, err := ParsePagination(map[string]string{}, nil)
+	suite.Nil(err)
+	suite.Nil(pagination)
+
+	pagination, err = ParsePagination(map[string]string{consts.PaginationKey: PaginationNone}, github.GetNewGithubPlugin())
+	suite.Nil(err)
+	suite.Nil(pagination)
+
+	// auto takes the integration's defaults
+	pagination, err = ParsePagination(map[string]string{consts.PaginationKey: PaginationAuto}, slack.GetNewSlackPlugin())
+	suite.Nil(err)
+	suite.Equal(types.PaginationCursor, pagination.Strategy)
+	suite.Equal("response_metadata.next_cursor", pagination.CursorPath)
+	suite.Equal(defaultPaginationMaxPages, pagination.MaxPages)
+
+	// user parameters override the defaults
+	pagination, err = ParsePagination(map[string]string{
+		consts.PaginationKey:         types.PaginationLink,
+		consts.PaginationPageSizeKey: "30",
+		consts.PaginationMaxItemsKey: "50",
+	}, github.GetNewGithubPlugin())
+	suite.Nil(err)
+	suite.Equal("per_page", pagination.LimitParam)
+	suite.Equal(30, pagination.PageSize)
+	suite.Equal(50, pagination.MaxItems)
+
+	for _, badScenario := range []struct {
+		parameters map[string]string
+		plugin     types.Plugin
+	}{
+		{parameters: map[string]string{consts.PaginationKey: PaginationAuto}},
+		{parameters: map[string]string{consts.PaginationKey: "scroll"}},
+		{parameters: map[string]string{consts.PaginationKey: types.PaginationCursor, consts.PaginationCursorPathKey: "next"}},
+		{parameters: map[string]string{consts.PaginationKey: types.PaginationOffset}},
+		{parameters: map[string]string{consts.PaginationKey: types.PaginationPage}},
+		{parameters: map[string]string{consts.PaginationKey: types.PaginationLink, consts.PaginationMaxPagesKey: "0"}},
+		{parameters: map[string]string{consts.PaginationKey: types.PaginationLink, consts.PaginationMaxItemsKey: "-1"}},
+	} {
+		_, err = ParsePagination(badScenario.parameters, badScenario.plugin)
+		suite.NotNil(err)
+	}
+}
+
+func (suite *PaginationTestSuite) TestGetNextLink() {
+	suite.Equal("https://api.github.com/user/repos?page=2", getNextLink([]string{
+		`<https://api.github.com/user/repos?page=2>; rel="next", <https://api.github.com/user/repos?page=5>; rel="last"`,
+	}))
+	suite.Equal("https://host.com/items?after=abc", getNextLink([]string{
+		`<https://host.com/items?after=xyz>; rel="self"`,
+		`<https://host.com/items?after=abc>; rel="next"`,
+	}))
+	suite.Equal("/items?page=3", getNextLink([]string{`</items?page=3>; rel="next last"`}))
+	suite.Equal("", getNextLink([]string{`<https://host.com/items?page=1>; rel="prev"`}))
+	suite.Equal("", getNextLink(nil))
+}
+
+func (suite *PaginationTestSuite) TestGetPageItems() {
+	items, err := getPageItems([]interface{}{1.0, 2.0}, "")
+	suite.Nil(err)
+	suite.Len(items, 2)
+
+	items, err = getPageItems(map[string]interface{}{"ok": true, "members": []interface{}{"a"}}, "")
+	suite.Nil(err)
+	suite.Equal([]interface{}{"a"}, items)
+
+	items, err = getPageItems(map[string]interface{}{"data": map[string]interface{}{"items": []interface{}{"a", "b"}}}, "data.items")
+	suite.Nil(err)
+	suite.Len(items, 2)
+
+	_, err = getPageItems(map[string]interface{}{"a": []interface{}{}, "b": []interface{}{}}, "")
+	suite.NotNil(err)
+
+	_, err = getPageItems(map[string]interface{}{"data": "value"}, "data")
+	suite.NotNil(err)
+}
+
+func (suite *PaginationTestSuite) TestPagerNext() {
+	pageUrl, err := url.Parse("https://host.com/issues?jql=project%3DABC")
+	suite.Nil(err)
+
+	offsetPager, err := newPager(&PaginationOptions{Pagination: types.Pagination{
+		Strategy:    types.PaginationOffset,
+		OffsetParam: "startAt",
+		LimitParam:  "maxResults",
+		PageSize:    2,
+	}}, pageUrl)
+	suite.Nil(err)
+	suite.Equal("2", pageUrl.Query().Get("maxResults"))
+	suite.Equal("0", pageUrl.Query().Get("startAt"))
+
+	nextUrl, err := offsetPager.next(pageUrl, &http.Response{}, nil, 2)
+	suite.Nil(err)
+	suite.Equal("2", nextUrl.Query().Get("startAt"))
+	suite.Equal("project=ABC", nextUrl.Query().Get("jql"))
+
+	// a short page is the last one
+	nextUrl, err = offsetPager.next(nextUrl, &http.Response{}, nil, 1)
+	suite.Nil(err)
+	suite.Nil(nextUrl)
+
+	cursorPager := &pager{options: &PaginationOptions{Pagination: types.Pagination{
+		Strategy:    types.PaginationCursor,
+		CursorPath:  "response_metadata.next_cursor",
+		CursorParam: "cursor",
+	}}}
+	body := map[string]interface{}{"response_metadata": map[string]interface{}{"next_cursor": "dXNlcjpVMEc5V0ZYTlo="}}
+	nextUrl, err = cursorPager.next(pageUrl, &http.Response{}, body, 10)
+	suite.Nil(err)
+	suite.Equal("dXNlcjpVMEc5V0ZYTlo=", nextUrl.Query().Get("cursor"))
+
+	body = map[string]interface{}{"response_metadata": map[string]interface{}{"next_cursor": ""}}
+	nextUrl, err = cursorPager.next(pageUrl, &http.Response{}, body, 10)
+	suite.Nil(err)
+	suite.Nil(nextUrl)
+
+	linkPager := &pager{options: &PaginationOptions{Pagination: types.Pagination{Strategy: types.PaginationLink}}}
+	response := &http.Response{Header: http.Header{"Link": []string{`</issues?page=2>; rel="next"`}}}
+	nextUrl, err = linkPager.next(pageUrl, response, nil, 10)
+	suite.Nil(err)
+	suite.Equal("https://host.com/issues?page=2", nextUrl.String())
+}
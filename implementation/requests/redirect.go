@@ -0,0 +1,63 @@
+package requests
+
+import (
+	"fmt"
+	"github.com/blinkops/blink-http/plugins/types"
+	"github.com/blinkops/blink-sdk/plugin/connections"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+)
+
+const defaultMaxRedirects = 10
+
+// headers that carry credentials even when they weren't set by the connection's auth
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "X-Api-Key", "Api-Key", "X-Auth-Token"}
+
+// newRedirectPolicy re-validates every redirect against the connections, the same way the initial url
+// is validated. when a redirect leaves the allowed host/path, the credentials are removed from it.
+// authHeaders returns the headers set by the connections' auth for the current attempt.
+func newRedirectPolicy(conns map[string]*connections.ConnectionInstance, plugin types.Plugin, options *RequestOptions, authHeaders func() []string) func(*http.Request, []*http.Request) error {
+	maxRedirects := options.MaxRedirects
+	if maxRedirects <= 0 {
+		maxRedirects = defaultMaxRedirects
+	}
+
+	return func(request *http.Request, via []*http.Request) error {
+		if options.DisableRedirects {
+			return http.ErrUseLastResponse
+		}
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+
+		for _, connInstance := range conns {
+			if err := validateURL(connInstance.Data, request.URL, plugin); err != nil {
+				log.Infof("redirect to %s leaves the connection's allowed url, removing credentials from the request", request.URL.Host)
+				stripCredentials(request.Header, authHeaders())
+				break
+			}
+		}
+		return nil
+	}
+}
+
+func stripCredentials(header http.Header, authHeaders []string) {
+	for _, name := range authHeaders {
+		header.Del(name)
+	}
+	for _, name := range credentialHeaders {
+		header.Del(name)
+	}
+}
+
+// getAuthHeaders returns the names of the headers that were added or changed between before and after
+func getAuthHeaders(before http.Header, after http.Header) []string {
+	var names []string
+	for name, values := range after {
+		previous, ok := before[name]
+		if !ok || fmt.Sprint(previous) != fmt.Sprint(values) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
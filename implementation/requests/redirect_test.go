@@ -0,0 +1,84 @@
+package requests
+
+import (
+	"github.com/blinkops/blink-http/consts"
+	"github.com/blinkops/blink-sdk/plugin/connections"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RedirectTestSuite struct {
+	suite.Suite
+}
+
+func TestRedirectTestSuite(t *testing.T) {
+	suite.Run(t, new(RedirectTestSuite))
+}
+
+func (suite *RedirectTestSuite) TestGetAuthHeaders() {
+	before := http.Header{"Accept": []string{"application/json"}, "X-Token": []string{"user"}}
+	after := http.Header{"Accept": []string{"application/json"}, "X-Token": []string{"connection"}, "Authorization": []string{"Bearer abc"}}
+	suite.ElementsMatch([]string{"X-Token", "Authorization"}, getAuthHeaders(before, after))
+}
+
+func (suite *RedirectTestSuite) TestRedirectPolicy() {
+	var receivedHeaders http.Header
+	external := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedHeaders = r.Header.Clone()
+	}))
+	defer external.Close()
+
+	var allowed *httptest.Server
+	allowed = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/external":
+			http.Redirect(w, r, external.URL+"/landing", http.StatusFound)
+		case "/internal":
+			http.Redirect(w, r, allowed.URL+"/landing", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, allowed.URL+"/loop", http.StatusFound)
+		default:
+			receivedHeaders = r.Header.Clone()
+		}
+	}))
+	defer allowed.Close()
+
+	conns := map[string]*connections.ConnectionInstance{
+		consts.ApiTokenKey: {Data: map[string]string{consts.RequestUrlKey: allowed.URL}},
+	}
+	authHeaders := func() []string { return []string{"X-Secret"} }
+
+	send := func(path string, options *RequestOptions) (*http.Response, error) {
+		receivedHeaders = nil
+		client := &http.Client{CheckRedirect: newRedirectPolicy(conns, nil, options, authHeaders)}
+		request, err := http.NewRequest(http.MethodGet, allowed.URL+path, nil)
+		suite.Require().Nil(err)
+		request.Header.Set("X-Secret", "value")
+		request.Header.Set("Accept", "application/json")
+		return client.Do(request)
+	}
+
+	// credentials are kept when the redirect stays on the allowed host
+	response, err := send("/internal", &RequestOptions{})
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, response.StatusCode)
+	suite.Equal("value", receivedHeaders.Get("X-Secret"))
+
+	// and removed when it leaves it
+	response, err = send("/external", &RequestOptions{})
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, response.StatusCode)
+	suite.Equal("", receivedHeaders.Get("X-Secret"))
+	suite.Equal("application/json", receivedHeaders.Get("Accept"))
+
+	response, err = send("/external", &RequestOptions{DisableRedirects: true})
+	suite.Nil(err)
+	suite.Equal(http.StatusFound, response.StatusCode)
+	suite.Nil(receivedHeaders)
+
+	_, err = send("/loop", &RequestOptions{MaxRedirects: 3})
+	suite.NotNil(err)
+}
@@ -0,0 +1,268 @@
+package requests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/blinkops/blink-http/consts"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultRetryBackoffBase = 500 * time.Millisecond
+	defaultRetryBackoffMax  = 30 * time.Second
+
+	// larger values are clamped, the attempts and the delays between them are bounded by the request timeout as well
+	maxRetryAttempts   = 10
+	maxRetryBackoffMax = 5 * time.Minute
+)
+
+var (
+	defaultRetryStatusCodes = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable}
+
+	// methods that can safely be sent more than once without changing the outcome
+	idempotentMethods = map[string]bool{
+		http.MethodGet:     true,
+		http.MethodHead:    true,
+		http.MethodOptions: true,
+		http.MethodTrace:   true,
+		http.MethodPut:     true,
+		http.MethodDelete:  true,
+	}
+
+	jitterLock sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+type RetryPolicy struct {
+	MaxAttempts        int
+	BackoffBase        time.Duration
+	BackoffMax         time.Duration
+	Jitter             bool
+	StatusCodes        map[int]bool
+	RetryNonIdempotent bool
+}
+
+// NoRetryPolicy sends the request exactly once.
+func NoRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{MaxAttempts: 1}
+}
+
+func ParseRetryPolicy(parameters map[string]string) (*RetryPolicy, error) {
+	policy := &RetryPolicy{
+		MaxAttempts: 1,
+		BackoffBase: defaultRetryBackoffBase,
+		BackoffMax:  defaultRetryBackoffMax,
+		StatusCodes: make(map[int]bool),
+	}
+
+	if value := parameters[consts.RetryMaxAttemptsKey]; value != "" {
+		maxAttempts, err := strconv.Atoi(value)
+		if err != nil || maxAttempts < 1 {
+			return nil, fmt.Errorf("invalid %s: %s, must be a positive integer", consts.RetryMaxAttemptsKey, value)
+		}
+		if maxAttempts > maxRetryAttempts {
+			maxAttempts = maxRetryAttempts
+		}
+		policy.MaxAttempts = maxAttempts
+	}
+
+	if value := parameters[consts.RetryBackoffBaseKey]; value != "" {
+		backoffBase, err := strconv.Atoi(value)
+		if err != nil || backoffBase < 0 {
+			return nil, fmt.Errorf("invalid %s: %s, must be a non negative number of milliseconds", consts.RetryBackoffBaseKey, value)
+		}
+		policy.BackoffBase = time.Duration(backoffBase) * time.Millisecond
+	}
+
+	if value := parameters[consts.RetryBackoffMaxKey]; value != "" {
+		backoffMax, err := strconv.Atoi(value)
+		if err != nil || backoffMax < 0 {
+			return nil, fmt.Errorf("invalid %s: %s, must be a non negative number of milliseconds", consts.RetryBackoffMaxKey, value)
+		}
+		policy.BackoffMax = time.Duration(backoffMax) * time.Millisecond
+		if policy.BackoffMax > maxRetryBackoffMax {
+			policy.BackoffMax = maxRetryBackoffMax
+		}
+	}
+
+	if value := parameters[consts.RetryJitterKey]; value != "" {
+		jitter, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s, must be a boolean", consts.RetryJitterKey, value)
+		}
+		policy.Jitter = jitter
+	}
+
+	if value := parameters[consts.RetryNonIdempotentKey]; value != "" {
+		retryNonIdempotent, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s, must be a boolean", consts.RetryNonIdempotentKey, value)
+		}
+		policy.RetryNonIdempotent = retryNonIdempotent
+	}
+
+	statusCodes := parameters[consts.RetryStatusCodesKey]
+	if strings.TrimSpace(statusCodes) == "" {
+		for _, code := range defaultRetryStatusCodes {
+			policy.StatusCodes[code] = true
+		}
+		return policy, nil
+	}
+
+	for _, value := range strings.Split(statusCodes, consts.ArrayDelimiter) {
+		code, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid %s: %s is not a valid http status code", consts.RetryStatusCodesKey, value)
+		}
+		policy.StatusCodes[code] = true
+	}
+
+	return policy, nil
+}
+
+func (p *RetryPolicy) shouldRetry(method string, response *http.Response, err error) bool {
+	if !idempotentMethods[method] && !p.RetryNonIdempotent {
+		return false
+	}
+	if err != nil {
+		return isRetryableError(err)
+	}
+	return response != nil && p.StatusCodes[response.StatusCode]
+}
+
+// delay returns how long to wait before the next attempt. the second return value
+// is false when the server asked us to wait longer than the backoff cap allows,
+// in which case there's no point in retrying.
+func (p *RetryPolicy) delay(attempt int, response *http.Response) (time.Duration, bool) {
+	backoff := p.BackoffBase
+	for i := 1; i < attempt && backoff < p.BackoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > p.BackoffMax {
+		backoff = p.BackoffMax
+	}
+	if p.Jitter && backoff > 0 {
+		jitterLock.Lock()
+		backoff = time.Duration(jitterRand.Int63n(int64(backoff) + 1))
+		jitterLock.Unlock()
+	}
+
+	if response == nil {
+		return backoff, true
+	}
+
+	serverDelay, ok := getServerRetryDelay(response.Header, time.Now())
+	if !ok {
+		return backoff, true
+	}
+	if serverDelay > p.BackoffMax {
+		return 0, false
+	}
+	if serverDelay > backoff {
+		return serverDelay, true
+	}
+	return backoff, true
+}
+
+// getServerRetryDelay reads the delay requested by the server from the Retry-After header
+// (seconds or http date) or from X-RateLimit-Reset (unix timestamp or seconds).
+func getServerRetryDelay(header http.Header, now time.Time) (time.Duration, bool) {
+	if value := strings.TrimSpace(header.Get("Retry-After")); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return nonNegative(time.Duration(seconds) * time.Second), true
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			return nonNegative(date.Sub(now)), true
+		}
+	}
+
+	if value := strings.TrimSpace(header.Get("X-RateLimit-Reset")); value != "" {
+		reset, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		// large values are unix timestamps (github, slack), small values are seconds until reset
+		if reset > 1000000000 {
+			return nonNegative(time.Unix(reset, 0).Sub(now)), true
+		}
+		return nonNegative(time.Duration(reset) * time.Second), true
+	}
+
+	return 0, false
+}
+
+func nonNegative(duration time.Duration) time.Duration {
+	if duration < 0 {
+		return 0
+	}
+	return duration
+}
+
+func isRetryableError(err error) bool {
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return false
+}
+
+// sendWithRetry sends the requests of newRequest until one succeeds or the attempts run out. the requests should use
+// requestContext, whose deadline bounds all the attempts together: no retry is made when its delay passes the deadline
+func sendWithRetry(requestContext context.Context, client *http.Client, policy *RetryPolicy, method string, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	if policy == nil {
+		policy = NoRetryPolicy()
+	}
+
+	for attempt := 1; ; attempt++ {
+		request, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := client.Do(request)
+		if attempt >= policy.MaxAttempts || !policy.shouldRetry(method, response, err) {
+			return response, err
+		}
+
+		delay, ok := policy.delay(attempt, response)
+		if !ok {
+			return response, err
+		}
+		if deadline, ok := requestContext.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			return response, err
+		}
+
+		if response != nil {
+			_, _ = io.Copy(ioutil.Discard, response.Body)
+			_ = response.Body.Close()
+			log.Debugf("request to %s failed with status %d, retrying in %v (attempt %d/%d)", request.URL, response.StatusCode, delay, attempt, policy.MaxAttempts)
+		} else {
+			log.Debugf("request to %s failed with error: %v, retrying in %v (attempt %d/%d)", request.URL, err, delay, attempt, policy.MaxAttempts)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-requestContext.Done():
+			timer.Stop()
+			return nil, requestContext.Err()
+		}
+	}
+}
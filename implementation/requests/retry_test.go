@@ -0,0 +1,179 @@
+package requests
+
+import (
+	"context"
+	"github.com/blinkops/blink-http/consts"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type RetryTestSuite struct {
+	suite.Suite
+}
+
+func TestRetryTestSuite(t *testing.T) {
+	suite.Run(t, new(RetryTestSuite))
+}
+
+func (suite *RetryTestSuite) TestParseRetryPolicy() {
+	policy, err := ParseRetryPolicy(map[string]string{})
+	suite.Nil(err)
+	suite.Equal(1, policy.MaxAttempts)
+	suite.True(policy.StatusCodes[http.StatusTooManyRequests])
+	suite.True(policy.StatusCodes[http.StatusServiceUnavailable])
+	suite.False(policy.StatusCodes[http.StatusInternalServerError])
+
+	policy, err = ParseRetryPolicy(map[string]string{
+		consts.RetryMaxAttemptsKey:   "5",
+		consts.RetryBackoffBaseKey:   "100",
+		consts.RetryBackoffMaxKey:    "1000",
+		consts.RetryJitterKey:        "true",
+		consts.RetryStatusCodesKey:   "500, 504",
+		consts.RetryNonIdempotentKey: "true",
+	})
+	suite.Nil(err)
+	suite.Equal(5, policy.MaxAttempts)
+	suite.Equal(100*time.Millisecond, policy.BackoffBase)
+	suite.Equal(time.Second, policy.BackoffMax)
+	suite.True(policy.Jitter)
+	suite.True(policy.RetryNonIdempotent)
+	suite.Equal(map[int]bool{500: true, 504: true}, policy.StatusCodes)
+
+	policy, err = ParseRetryPolicy(map[string]string{
+		consts.RetryMaxAttemptsKey: "1000",
+		consts.RetryBackoffMaxKey:  "86400000",
+	})
+	suite.Nil(err)
+	suite.Equal(maxRetryAttempts, policy.MaxAttempts)
+	suite.Equal(maxRetryBackoffMax, policy.BackoffMax)
+
+	for _, badParameters := range []map[string]string{
+		{consts.RetryMaxAttemptsKey: "0"},
+		{consts.RetryMaxAttemptsKey: "many"},
+		{consts.RetryBackoffBaseKey: "-1"},
+		{consts.RetryJitterKey: "sometimes"},
+		{consts.RetryStatusCodesKey: "429,abc"},
+		{consts.RetryStatusCodesKey: "999"},
+	} {
+		_, err = ParseRetryPolicy(badParameters)
+		suite.NotNil(err)
+	}
+}
+
+func (suite *RetryTestSuite) TestDelay() {
+	policy := &RetryPolicy{MaxAttempts: 5, BackoffBase: 100 * time.Millisecond, BackoffMax: 300 * time.Millisecond}
+
+	delay, ok := policy.delay(1, nil)
+	suite.True(ok)
+	suite.Equal(100*time.Millisecond, delay)
+
+	delay, ok = policy.delay(2, nil)
+	suite.True(ok)
+	suite.Equal(200*time.Millisecond, delay)
+
+	delay, ok = policy.delay(4, nil)
+	suite.True(ok)
+	suite.Equal(300*time.Millisecond, delay)
+
+	// the server asked to wait longer than the cap
+	response := &http.Response{Header: http.Header{"Retry-After": []string{"10"}}}
+	_, ok = policy.delay(1, response)
+	suite.False(ok)
+}
+
+func (suite *RetryTestSuite) TestGetServerRetryDelay() {
+	now := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	delay, ok := getServerRetryDelay(http.Header{"Retry-After": []string{"3"}}, now)
+	suite.True(ok)
+	suite.Equal(3*time.Second, delay)
+
+	delay, ok = getServerRetryDelay(http.Header{"Retry-After": []string{now.Add(5 * time.Second).Format(http.TimeFormat)}}, now)
+	suite.True(ok)
+	suite.Equal(5*time.Second, delay)
+
+	delay, ok = getServerRetryDelay(http.Header{"X-Ratelimit-Reset": []string{"7"}}, now)
+	suite.True(ok)
+	suite.Equal(7*time.Second, delay)
+
+	delay, ok = getServerRetryDelay(http.Header{"X-Ratelimit-Reset": []string{"1633089610"}}, now)
+	suite.True(ok)
+	suite.Equal(10*time.Second, delay)
+
+	_, ok = getServerRetryDelay(http.Header{}, now)
+	suite.False(ok)
+}
+
+func (suite *RetryTestSuite) TestSendWithRetry() {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	newRequest := func(method string) func() (*http.Request, error) {
+		return func() (*http.Request, error) {
+			return http.NewRequest(method, server.URL, strings.NewReader("body"))
+		}
+	}
+	policy := &RetryPolicy{MaxAttempts: 3, StatusCodes: map[int]bool{http.StatusServiceUnavailable: true}}
+
+	response, err := sendWithRetry(context.Background(), server.Client(), policy, http.MethodGet, newRequest(http.MethodGet))
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, response.StatusCode)
+	suite.Equal(int32(3), atomic.LoadInt32(&calls))
+
+	// non idempotent methods are sent once unless explicitly allowed
+	atomic.StoreInt32(&calls, 0)
+	response, err = sendWithRetry(context.Background(), server.Client(), policy, http.MethodPost, newRequest(http.MethodPost))
+	suite.Nil(err)
+	suite.Equal(http.StatusServiceUnavailable, response.StatusCode)
+	suite.Equal(int32(1), atomic.LoadInt32(&calls))
+
+	atomic.StoreInt32(&calls, 0)
+	policy.RetryNonIdempotent = true
+	response, err = sendWithRetry(context.Background(), server.Client(), policy, http.MethodPost, newRequest(http.MethodPost))
+	suite.Nil(err)
+	suite.Equal(http.StatusOK, response.StatusCode)
+	suite.Equal(int32(3), atomic.LoadInt32(&calls))
+}
+
+func (suite *RetryTestSuite) TestSendWithRetryDeadline() {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	requestContext, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	newRequest := func() (*http.Request, error) {
+		return http.NewRequestWithContext(requestContext, http.MethodGet, server.URL, nil)
+	}
+	policy := &RetryPolicy{
+		MaxAttempts: 10,
+		BackoffBase: 300 * time.Millisecond,
+		BackoffMax:  time.Minute,
+		StatusCodes: map[int]bool{http.StatusServiceUnavailable: true},
+	}
+
+	// the second retry would wait until after the deadline, so the last response is returned instead
+	start := time.Now()
+	response, err := sendWithRetry(requestContext, server.Client(), policy, http.MethodGet, newRequest)
+	suite.Nil(err)
+	suite.Equal(http.StatusServiceUnavailable, response.StatusCode)
+	suite.Equal(int32(2), atomic.LoadInt32(&calls))
+	suite.Less(int64(time.Since(start)), int64(500*time.Millisecond))
+}
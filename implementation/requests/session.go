@@ -0,0 +1,164 @@
+package requests
+
+import (
+	"fmt"
+	"github.com/blinkops/blink-http/plugins/tokens"
+	"github.com/blinkops/blink-sdk/plugin"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+const DefaultSessionTtl = 30 * time.Minute
+
+// Session is a cookie jar kept between actions, so cookies set by a login request are sent by the following requests.
+// a session expires when it isn't used for its ttl
+type Session struct {
+	lock    sync.Mutex
+	jar     *cookiejar.Jar
+	urls    map[string]*url.URL
+	expires time.Time
+}
+
+type sessionStore struct {
+	lock     sync.Mutex
+	sessions map[string]*Session
+	now      func() time.Time
+}
+
+var sessions = &sessionStore{sessions: map[string]*Session{}, now: time.Now}
+
+// GetSession returns the session of the id, creating it when it doesn't exist or expired.
+// sessions are scoped to the action's connections, so runbooks with other credentials can't reuse them
+func GetSession(ctx *plugin.ActionContext, sessionId string, ttl time.Duration) (*Session, error) {
+	if ttl <= 0 {
+		ttl = DefaultSessionTtl
+	}
+	return sessions.get(getSessionKey(ctx, sessionId), ttl)
+}
+
+// ClearSession removes the session of the id, it returns false when there was no such session
+func ClearSession(ctx *plugin.ActionContext, sessionId string) bool {
+	return sessions.clear(getSessionKey(ctx, sessionId))
+}
+
+// LookupSession returns the session of the id without creating it, or nil when there is no such session
+func LookupSession(ctx *plugin.ActionContext, sessionId string) *Session {
+	return sessions.lookup(getSessionKey(ctx, sessionId))
+}
+
+func (s *sessionStore) get(key string, ttl time.Duration) (*Session, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.now()
+	s.removeExpired(now)
+
+	session, ok := s.sessions[key]
+	if !ok {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cookie jar, error: %v", err)
+		}
+		session = &Session{jar: jar, urls: map[string]*url.URL{}}
+		s.sessions[key] = session
+	}
+	session.expires = now.Add(ttl)
+	return session, nil
+}
+
+func (s *sessionStore) lookup(key string) *Session {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.removeExpired(s.now())
+	return s.sessions[key]
+}
+
+func (s *sessionStore) clear(key string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.removeExpired(s.now())
+	_, ok := s.sessions[key]
+	delete(s.sessions, key)
+	return ok
+}
+
+// removeExpired is called with the lock held
+func (s *sessionStore) removeExpired(now time.Time) {
+	for key, session := range s.sessions {
+		if !now.Before(session.expires) {
+			delete(s.sessions, key)
+		}
+	}
+}
+
+func getSessionKey(ctx *plugin.ActionContext, sessionId string) string {
+	connections := map[string]string{}
+	for connName, connInstance := range ctx.GetAllConnections() {
+		connections[connName] = tokens.GetCacheKey(connName, connInstance.Data)
+	}
+	return tokens.GetCacheKey(sessionId, connections)
+}
+
+func (s *Session) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// the urls are kept, since the jar can only list the cookies of a url
+	if len(cookies) > 0 {
+		cookieUrl := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}
+		s.urls[cookieUrl.String()] = cookieUrl
+	}
+	s.jar.SetCookies(u, cookies)
+}
+
+func (s *Session) Cookies(u *url.URL) []*http.Cookie {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.jar.Cookies(u)
+}
+
+// GetCookies returns the unexpired cookies of the session
+func (s *Session) GetCookies() []EnvelopeCookie {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	keys := make([]string, 0, len(s.urls))
+	for key := range s.urls {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	cookies := []EnvelopeCookie{}
+	seen := map[string]bool{}
+	for _, key := range keys {
+		cookieUrl := s.urls[key]
+		for _, cookie := range s.jar.Cookies(cookieUrl) {
+			id := cookieUrl.Host + "\x00" + cookie.Name
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			cookies = append(cookies, EnvelopeCookie{
+				Name:   cookie.Name,
+				Value:  cookie.Value,
+				Domain: cookieUrl.Hostname(),
+			})
+		}
+	}
+	return cookies
+}
+
+// Expires returns when the session expires if it's not used again
+func (s *Session) Expires() time.Time {
+	sessions.lock.Lock()
+	defer sessions.lock.Unlock()
+
+	return s.expires
+}
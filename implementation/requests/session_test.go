@@ -0,0 +1,106 @@
+package requests
+
+import (
+	"github.com/blinkops/blink-http/consts"
+	"github.com/blinkops/blink-sdk/plugin"
+	"github.com/blinkops/blink-sdk/plugin/connections"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SessionTestSuite struct {
+	suite.Suite
+	now time.Time
+}
+
+func TestSessionTestSuite(t *testing.T) {
+	suite.Run(t, new(SessionTestSuite))
+}
+
+func (suite *SessionTestSuite) SetupTest() {
+	suite.now = time.Unix(1700000000, 0)
+	sessions = &sessionStore{sessions: map[string]*Session{}, now: func() time.Time { return suite.now }}
+}
+
+func (suite *SessionTestSuite) TearDownTest() {
+	sessions = &sessionStore{sessions: map[string]*Session{}, now: time.Now}
+}
+
+func (suite *SessionTestSuite) newContext(requestUrl string, token string) *plugin.ActionContext {
+	return plugin.NewActionContext(nil, map[string]*connections.ConnectionInstance{
+		consts.BearerAuthKey: {Data: map[string]string{consts.RequestUrlKey: requestUrl, consts.TokenKey: token}},
+	})
+}
+
+func (suite *SessionTestSuite) TestSessionKeepsCookies() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc=", Path: "/"})
+			return
+		}
+		cookie, err := r.Cookie("session")
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(cookie.Value))
+	}))
+	defer server.Close()
+
+	ctx := suite.newContext(server.URL, "token")
+	session, err := GetSession(ctx, "login", time.Minute)
+	suite.Require().Nil(err)
+
+	_, err = SendRequestWithOptions(ctx, nil, http.MethodPost, server.URL+"/login", 5, nil, nil, nil, &RequestOptions{Session: session})
+	suite.Nil(err)
+
+	session, err = GetSession(ctx, "login", time.Minute)
+	suite.Require().Nil(err)
+	body, err := SendRequestWithOptions(ctx, nil, http.MethodGet, server.URL+"/me", 5, nil, nil, nil, &RequestOptions{Session: session})
+	suite.Nil(err)
+	suite.Equal("abc=", string(body))
+
+	cookies := LookupSession(ctx, "login").GetCookies()
+	suite.Require().Len(cookies, 1)
+	suite.Equal("session", cookies[0].Name)
+	suite.Equal("abc=", cookies[0].Value)
+
+	// requests without the session don't send its cookies
+	_, err = SendRequest(ctx, nil, http.MethodGet, server.URL+"/me", 5, nil, nil, nil)
+	suite.NotNil(err)
+}
+
+func (suite *SessionTestSuite) TestSessionScope() {
+	session, err := GetSession(suite.newContext("https://example.com", "token"), "login", time.Minute)
+	suite.Require().Nil(err)
+
+	suite.Equal(session, LookupSession(suite.newContext("https://example.com", "token"), "login"))
+	suite.Nil(LookupSession(suite.newContext("https://example.com", "other token"), "login"))
+	suite.Nil(LookupSession(suite.newContext("https://example.com", "token"), "other"))
+}
+
+func (suite *SessionTestSuite) TestSessionExpiry() {
+	ctx := suite.newContext("https://example.com", "token")
+	_, err := GetSession(ctx, "login", time.Minute)
+	suite.Require().Nil(err)
+
+	// using the session extends it
+	suite.now = suite.now.Add(50 * time.Second)
+	_, err = GetSession(ctx, "login", time.Minute)
+	suite.Require().Nil(err)
+	suite.now = suite.now.Add(50 * time.Second)
+	suite.NotNil(LookupSession(ctx, "login"))
+
+	suite.now = suite.now.Add(time.Minute)
+	suite.Nil(LookupSession(ctx, "login"))
+
+	_, err = GetSession(ctx, "login", time.Minute)
+	suite.Require().Nil(err)
+	suite.True(ClearSession(ctx, "login"))
+	suite.False(ClearSession(ctx, "login"))
+	suite.Nil(LookupSession(ctx, "login"))
+}
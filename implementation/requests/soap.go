@@ -0,0 +1,449 @@
+package requests
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/blinkops/blink-http/plugins/types"
+	"github.com/blinkops/blink-sdk/plugin"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	soap11EnvelopeNamespace = "http://schemas.xmlsoap.org/soap/envelope/"
+	soap12EnvelopeNamespace = "http://www.w3.org/2003/05/soap-envelope"
+
+	wsseNamespace          = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd"
+	wsuNamespace           = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd"
+	wsUsernameTokenProfile = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-username-token-profile-1.0"
+	wsBase64Encoding       = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-soap-message-security-1.0#Base64Binary"
+
+	WsPasswordText   = "PasswordText"
+	WsPasswordDigest = "PasswordDigest"
+)
+
+// WsSecurity adds a WS-Security UsernameToken header to the envelope
+type WsSecurity struct {
+	Username     string
+	Password     string
+	PasswordType string
+}
+
+// SoapFault is the error of a response with a Fault body
+type SoapFault struct {
+	Code    string
+	Message string
+}
+
+func (f *SoapFault) Error() string {
+	return fmt.Sprintf("soap fault %s: %s", f.Code, f.Message)
+}
+
+// jsonField keeps the order of the object's fields, the order of xml elements matters to most SOAP services
+type jsonField struct {
+	name  string
+	value interface{}
+}
+
+// LoadWsdl reads the WSDL from an http(s) url with the connection's auth, from an inline document or from a local file
+func LoadWsdl(ctx *plugin.ActionContext, plugin types.Plugin, location string, timeout int32) (*Wsdl, error) {
+	location = strings.TrimSpace(location)
+	if location == "" {
+		return nil, errors.New("no wsdl provided")
+	}
+
+	var data []byte
+	switch {
+	case strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://"):
+		_, body, err := sendRequest(ctx, plugin, http.MethodGet, location, timeout, http.Header{}, nil, nil, &RequestOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch wsdl, error: %v", err)
+		}
+		data = body
+	case strings.HasPrefix(location, "<"):
+		data = []byte(location)
+	default:
+		body, err := ioutil.ReadFile(strings.TrimPrefix(location, "file://"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read wsdl, error: %v", err)
+		}
+		data = body
+	}
+
+	return ParseWsdl(data)
+}
+
+// SendSoapRequest posts the envelope of the operation to the url (or to the endpoint of the WSDL when it's empty)
+// and returns the content of the response body as JSON. Fault responses fail with their code and message
+func SendSoapRequest(ctx *plugin.ActionContext, plugin types.Plugin, urlString string, timeout int32, headers http.Header, operation *SoapOperation, params string, security *WsSecurity, options *RequestOptions) ([]byte, error) {
+	if options == nil {
+		options = &RequestOptions{}
+	}
+
+	if urlString == "" {
+		urlString = operation.Endpoint
+	}
+	if urlString == "" {
+		return nil, fmt.Errorf("the wsdl has no endpoint for %s, a url must be provided", operation.Name)
+	}
+
+	envelope, err := BuildSoapEnvelope(operation, params, security)
+	if err != nil {
+		return nil, err
+	}
+
+	if headers == nil {
+		headers = http.Header{}
+	}
+	if operation.SoapVersion == SoapVersion12 {
+		contentType := "application/soap+xml; charset=utf-8"
+		if operation.SoapAction != "" {
+			contentType += fmt.Sprintf("; action=%q", operation.SoapAction)
+		}
+		headers.Set("Content-Type", contentType)
+	} else {
+		headers.Set("Content-Type", "text/xml; charset=utf-8")
+		headers.Set("SOAPAction", strconv.Quote(operation.SoapAction))
+	}
+
+	start := time.Now()
+	response, body, err := sendRequest(ctx, plugin, http.MethodPost, urlString, timeout, headers, nil, envelope, options)
+	if body != nil {
+		body, err = unwrapSoapResponse(body, err)
+	}
+	return formatResponse(http.MethodPost, response, body, err, time.Since(start), options)
+}
+
+// BuildSoapEnvelope returns the envelope of the operation. The params of rpc operations and of document operations
+// with several parts are a JSON object keyed by the part names, the params of a document operation with a single
+// part are the content of its element. "@name" keys are attributes, "#text" is the text of an element with
+// attributes, arrays are repeated elements and null is an xsi:nil element
+func BuildSoapEnvelope(operation *SoapOperation, params string, security *WsSecurity) ([]byte, error) {
+	var content interface{} = []jsonField{}
+	if strings.TrimSpace(params) != "" {
+		decoder := json.NewDecoder(strings.NewReader(params))
+		decoder.UseNumber()
+		value, err := decodeOrderedJson(decoder)
+		if err == nil && decoder.More() {
+			err = errors.New("unexpected data after the params")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid soap params, error: %v", err)
+		}
+		content = value
+	}
+
+	envelopeNamespace := soap11EnvelopeNamespace
+	if operation.SoapVersion == SoapVersion12 {
+		envelopeNamespace = soap12EnvelopeNamespace
+	}
+
+	envelope := &strings.Builder{}
+	envelope.WriteString(xml.Header)
+	fmt.Fprintf(envelope, `<soap:Envelope xmlns:soap="%s" xmlns:xsi="%s">`, envelopeNamespace, xmlSchemaInstance)
+	if security != nil {
+		header, err := security.header(operation.SoapVersion)
+		if err != nil {
+			return nil, err
+		}
+		envelope.WriteString("<soap:Header>" + header + "</soap:Header>")
+	}
+	envelope.WriteString("<soap:Body>")
+	if err := writeSoapBody(envelope, operation, content); err != nil {
+		return nil, err
+	}
+	envelope.WriteString("</soap:Body></soap:Envelope>")
+
+	return []byte(envelope.String()), nil
+}
+
+func writeSoapBody(envelope *strings.Builder, operation *SoapOperation, content interface{}) error {
+	if operation.Style != "rpc" && len(operation.Parts) == 1 {
+		return writeSoapPart(envelope, operation.Parts[0], content)
+	}
+
+	fields, ok := content.([]jsonField)
+	if !ok {
+		return fmt.Errorf("soap params of %s must be a JSON object keyed by the part names", operation.Name)
+	}
+	values := map[string]interface{}{}
+	for _, field := range fields {
+		values[field.name] = field.value
+	}
+	for name := range values {
+		if operation.getPart(name) == nil {
+			return fmt.Errorf("%s has no part named %s", operation.Name, name)
+		}
+	}
+
+	if operation.Style == "rpc" {
+		fmt.Fprintf(envelope, `<op:%s xmlns:op="%s">`, operation.Name, escapeXml(operation.namespace))
+	}
+	// the parts are written in the order of the message, missing parts are left out
+	for _, part := range operation.Parts {
+		value, ok := values[part.Name]
+		if !ok {
+			continue
+		}
+		if operation.Style == "rpc" {
+			if err := writeXmlElement(envelope, part.Name, "", value, ""); err != nil {
+				return err
+			}
+		} else if err := writeSoapPart(envelope, part, value); err != nil {
+			return err
+		}
+	}
+	if operation.Style == "rpc" {
+		fmt.Fprintf(envelope, `</op:%s>`, operation.Name)
+	}
+	return nil
+}
+
+func writeSoapPart(envelope *strings.Builder, part *SoapPart, value interface{}) error {
+	// parts of a type rather than an element are named after the part
+	if part.local == "" {
+		return writeXmlElement(envelope, part.Name, "", value, "")
+	}
+
+	childPrefix := ""
+	if part.qualified {
+		childPrefix = "ns:"
+	}
+	return writeXmlElement(envelope, "ns:"+part.local, fmt.Sprintf(` xmlns:ns="%s"`, escapeXml(part.namespace)), value, childPrefix)
+}
+
+// writeXmlElement writes the value as the element, the children's names get the prefix
+func writeXmlElement(builder *strings.Builder, name string, namespaceAttr string, value interface{}, childPrefix string) error {
+	if items, ok := value.([]interface{}); ok {
+		for _, item := range items {
+			if err := writeXmlElement(builder, name, namespaceAttr, item, childPrefix); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	builder.WriteString("<" + name + namespaceAttr)
+	if value == nil {
+		builder.WriteString(` xsi:nil="true"/>`)
+		return nil
+	}
+
+	fields, ok := value.([]jsonField)
+	if !ok {
+		builder.WriteString(">" + escapeXml(formatXmlValue(value)) + "</" + name + ">")
+		return nil
+	}
+
+	for _, field := range fields {
+		if !strings.HasPrefix(field.name, xmlAttributePrefix) {
+			continue
+		}
+		attribute := strings.TrimPrefix(field.name, xmlAttributePrefix)
+		if !isXmlName(attribute) {
+			return fmt.Errorf("invalid xml attribute name: %s", attribute)
+		}
+		switch field.value.(type) {
+		case []jsonField, []interface{}:
+			return fmt.Errorf("the value of the xml attribute %s must be a scalar", attribute)
+		}
+		builder.WriteString(fmt.Sprintf(` %s="%s"`, attribute, escapeXml(formatXmlValue(field.value))))
+	}
+	builder.WriteString(">")
+
+	for _, field := range fields {
+		switch {
+		case strings.HasPrefix(field.name, xmlAttributePrefix):
+		case field.name == xmlTextKey:
+			builder.WriteString(escapeXml(formatXmlValue(field.value)))
+		case !isXmlName(field.name):
+			return fmt.Errorf("invalid xml element name: %s", field.name)
+		default:
+			if err := writeXmlElement(builder, childPrefix+field.name, "", field.value, childPrefix); err != nil {
+				return err
+			}
+		}
+	}
+
+	builder.WriteString("</" + name + ">")
+	return nil
+}
+
+func (o *SoapOperation) getPart(name string) *SoapPart {
+	for _, part := range o.Parts {
+		if part.Name == name {
+			return part
+		}
+	}
+	return nil
+}
+
+// header returns the Security header, the nonce and creation time are sent with both password types
+func (s *WsSecurity) header(version string) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate ws-security nonce, error: %v", err)
+	}
+	created := time.Now().UTC().Format("2006-01-02T15:04:05Z")
+
+	passwordType := s.PasswordType
+	if passwordType == "" {
+		passwordType = WsPasswordText
+	}
+	password := s.Password
+	switch passwordType {
+	case WsPasswordText:
+	case WsPasswordDigest:
+		digest := sha1.Sum(append(append(nonce, created...), s.Password...))
+		password = base64.StdEncoding.EncodeToString(digest[:])
+	default:
+		return "", fmt.Errorf("invalid ws-security password type: %s, must be %s or %s", passwordType, WsPasswordText, WsPasswordDigest)
+	}
+
+	mustUnderstand := "1"
+	if version == SoapVersion12 {
+		mustUnderstand = "true"
+	}
+
+	header := &strings.Builder{}
+	fmt.Fprintf(header, `<wsse:Security xmlns:wsse="%s" xmlns:wsu="%s" soap:mustUnderstand="%s">`, wsseNamespace, wsuNamespace, mustUnderstand)
+	header.WriteString("<wsse:UsernameToken>")
+	header.WriteString("<wsse:Username>" + escapeXml(s.Username) + "</wsse:Username>")
+	fmt.Fprintf(header, `<wsse:Password Type="%s#%s">%s</wsse:Password>`, wsUsernameTokenProfile, passwordType, escapeXml(password))
+	fmt.Fprintf(header, `<wsse:Nonce EncodingType="%s">%s</wsse:Nonce>`, wsBase64Encoding, base64.StdEncoding.EncodeToString(nonce))
+	header.WriteString("<wsu:Created>" + created + "</wsu:Created>")
+	header.WriteString("</wsse:UsernameToken></wsse:Security>")
+	return header.String(), nil
+}
+
+// unwrapSoapResponse returns the content of the response's Body as JSON, the fault of a Fault body is the error
+func unwrapSoapResponse(body []byte, err error) ([]byte, error) {
+	root, parseErr := parseXml(body)
+	if parseErr != nil || root.name.Local != "Envelope" || root.child("Body") == nil {
+		if err != nil {
+			return body, err
+		}
+		return body, errors.New("invalid soap response, the response is not a soap envelope")
+	}
+
+	soapBody := root.child("Body")
+	if fault := soapBody.child("Fault"); fault != nil {
+		faultJson, marshalErr := json.Marshal(fault.value())
+		if marshalErr != nil {
+			return body, marshalErr
+		}
+		return faultJson, getSoapFault(fault)
+	}
+	if err != nil {
+		return body, err
+	}
+
+	content, marshalErr := json.Marshal(soapBody.value())
+	if marshalErr != nil {
+		return nil, marshalErr
+	}
+	return content, nil
+}
+
+// getSoapFault reads the SOAP 1.1 faultcode and faultstring or the SOAP 1.2 Code and Reason of the fault
+func getSoapFault(fault *xmlNode) *SoapFault {
+	soapFault := &SoapFault{}
+	if code := fault.child("faultcode"); code != nil {
+		soapFault.Code = strings.TrimSpace(code.text.String())
+	} else if code = fault.child("Code"); code != nil {
+		if value := code.child("Value"); value != nil {
+			soapFault.Code = strings.TrimSpace(value.text.String())
+		}
+	}
+	if message := fault.child("faultstring"); message != nil {
+		soapFault.Message = strings.TrimSpace(message.text.String())
+	} else if reason := fault.child("Reason"); reason != nil {
+		if text := reason.child("Text"); text != nil {
+			soapFault.Message = strings.TrimSpace(text.text.String())
+		}
+	}
+	return soapFault
+}
+
+// decodeOrderedJson decodes the next value, objects are decoded to []jsonField
+func decodeOrderedJson(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		fields := []jsonField{}
+		for decoder.More() {
+			name, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrderedJson(decoder)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, jsonField{name: name.(string), value: value})
+		}
+		_, err = decoder.Token()
+		return fields, err
+	case json.Delim('['):
+		items := []interface{}{}
+		for decoder.More() {
+			item, err := decodeOrderedJson(decoder)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		_, err = decoder.Token()
+		return items, err
+	default:
+		return token, nil
+	}
+}
+
+func formatXmlValue(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func escapeXml(value string) string {
+	escaped := &bytes.Buffer{}
+	_ = xml.EscapeText(escaped, []byte(value))
+	return escaped.String()
+}
+
+// isXmlName reports whether the name can be used as an unprefixed element or attribute name
+func isXmlName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if unicode.IsLetter(r) || r == '_' {
+			continue
+		}
+		if i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.') {
+			continue
+		}
+		return false
+	}
+	return true
+}
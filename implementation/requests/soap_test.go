@@ -0,0 +1,241 @@
+package requests
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/blinkops/blink-http/consts"
+	"github.com/blinkops/blink-sdk/plugin"
+	"github.com/blinkops/blink-sdk/plugin/connections"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+const testWsdl = `<?xml version="1.0" encoding="UTF-8"?>
+<definitions xmlns="http://schemas.xmlsoap.org/wsdl/" xmlns:soap="http://schemas.xmlsoap.org/wsdl/soap/"
+	xmlns:soap12="http://schemas.xmlsoap.org/wsdl/soap12/" xmlns:tns="urn:stock" xmlns:xsd="http://www.w3.org/2001/XMLSchema"
+	targetNamespace="urn:stock">
+	<types>
+		<xsd:schema targetNamespace="urn:stock" elementFormDefault="qualified">
+			<xsd:element name="GetPrice"/>
+		</xsd:schema>
+	</types>
+	<message name="GetPriceInput"><part name="parameters" element="tns:GetPrice"/></message>
+	<message name="AddInput"><part name="a" type="xsd:int"/><part name="b" type="xsd:int"/></message>
+	<portType name="StockPortType">
+		<operation name="GetPrice"><input message="tns:GetPriceInput"/></operation>
+	</portType>
+	<portType name="CalculatorPortType">
+		<operation name="Add"><input message="tns:AddInput"/></operation>
+	</portType>
+	<binding name="StockSoap" type="tns:StockPortType">
+		<soap:binding style="document" transport="http://schemas.xmlsoap.org/soap/http"/>
+		<operation name="GetPrice">
+			<soap:operation soapAction="urn:stock/GetPrice"/>
+			<input><soap:body use="literal"/></input>
+		</operation>
+	</binding>
+	<binding name="StockSoap12" type="tns:StockPortType">
+		<soap12:binding style="document" transport="http://schemas.xmlsoap.org/soap/http"/>
+		<operation name="GetPrice">
+			<soap12:operation soapAction="urn:stock/GetPrice"/>
+			<input><soap12:body use="literal"/></input>
+		</operation>
+	</binding>
+	<binding name="CalculatorSoap" type="tns:CalculatorPortType">
+		<soap:binding style="rpc" transport="http://schemas.xmlsoap.org/soap/http"/>
+		<operation name="Add">
+			<soap:operation soapAction=""/>
+			<input><soap:body use="literal" namespace="urn:calculator"/></input>
+		</operation>
+	</binding>
+	<service name="StockService">
+		<port name="StockPort" binding="tns:StockSoap"><soap:address location="%[1]s/stock"/></port>
+		<port name="StockPort12" binding="tns:StockSoap12"><soap12:address location="%[1]s/stock"/></port>
+		<port name="CalculatorPort" binding="tns:CalculatorSoap"><soap:address location="%[1]s/calculator"/></port>
+	</service>
+</definitions>`
+
+type SoapTestSuite struct {
+	suite.Suite
+	ctx    *plugin.ActionContext
+	server *httptest.Server
+}
+
+func TestSoapTestSuite(t *testing.T) {
+	suite.Run(t, new(SoapTestSuite))
+}
+
+func (suite *SoapTestSuite) SetupTest() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stock.wsdl", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = fmt.Fprintf(w, testWsdl, suite.server.URL)
+	})
+	mux.HandleFunc("/stock", func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		suite.Require().Nil(err)
+
+		root, err := parseXml(body)
+		suite.Require().Nil(err)
+		price := root.child("Body").child("GetPrice")
+		suite.Require().NotNil(price)
+		suite.Equal("urn:stock", price.name.Space)
+
+		if r.Header.Get("Content-Type") == "text/xml; charset=utf-8" {
+			suite.Equal(`"urn:stock/GetPrice"`, r.Header.Get("SOAPAction"))
+			suite.Equal(soap11EnvelopeNamespace, root.name.Space)
+		} else {
+			suite.Equal(`application/soap+xml; charset=utf-8; action="urn:stock/GetPrice"`, r.Header.Get("Content-Type"))
+			suite.Equal(soap12EnvelopeNamespace, root.name.Space)
+		}
+
+		w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+		if symbol := price.child("Symbol"); symbol == nil || symbol.text.String() != "BLNK" {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = fmt.Fprint(w, `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><soap:Fault>`+
+				`<faultcode>soap:Client</faultcode><faultstring>Unknown symbol</faultstring></soap:Fault></soap:Body></soap:Envelope>`)
+			return
+		}
+		_, _ = fmt.Fprint(w, `<?xml version="1.0" encoding="ISO-8859-1"?><soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body>`+
+			`<m:GetPriceResponse xmlns:m="urn:stock"><m:Price currency="USD">1.90</m:Price></m:GetPriceResponse></soap:Body></soap:Envelope>`)
+	})
+	suite.server = httptest.NewServer(mux)
+	suite.ctx = plugin.NewActionContext(nil, map[string]*connections.ConnectionInstance{
+		consts.BearerAuthKey: {Data: map[string]string{consts.RequestUrlKey: suite.server.URL, consts.TokenKey: "token"}},
+	})
+}
+
+func (suite *SoapTestSuite) TearDownTest() {
+	suite.server.Close()
+}
+
+func (suite *SoapTestSuite) TestLoadWsdl() {
+	wsdl, err := LoadWsdl(suite.ctx, nil, suite.server.URL+"/stock.wsdl", 5)
+	suite.Require().Nil(err)
+	suite.Require().Len(wsdl.Operations, 3)
+
+	add, err := wsdl.GetOperation("Add", "")
+	suite.Nil(err)
+	suite.Equal("rpc", add.Style)
+	suite.Equal("urn:calculator", add.namespace)
+	suite.Equal(suite.server.URL+"/calculator", add.Endpoint)
+	suite.Len(add.Parts, 2)
+
+	price, err := wsdl.GetOperation("GetPrice", "")
+	suite.Nil(err)
+	suite.Equal(SoapVersion11, price.SoapVersion)
+	suite.Equal("urn:stock/GetPrice", price.SoapAction)
+	suite.Require().Len(price.Parts, 1)
+	suite.Equal(SoapPart{Name: "parameters", Element: "tns:GetPrice", namespace: "urn:stock", local: "GetPrice", qualified: true}, *price.Parts[0])
+
+	price, err = wsdl.GetOperation("GetPrice", SoapVersion12)
+	suite.Nil(err)
+	suite.Equal(SoapVersion12, price.SoapVersion)
+
+	_, err = wsdl.GetOperation("Add", SoapVersion12)
+	suite.NotNil(err)
+
+	path := filepath.Join(suite.T().TempDir(), "stock.wsdl")
+	suite.Require().Nil(ioutil.WriteFile(path, []byte(fmt.Sprintf(testWsdl, "http://localhost")), os.ModePerm))
+	wsdl, err = LoadWsdl(suite.ctx, nil, "file://"+path, 5)
+	suite.Nil(err)
+	suite.Len(wsdl.Operations, 3)
+
+	_, err = LoadWsdl(suite.ctx, nil, `<definitions xmlns="http://schemas.xmlsoap.org/wsdl/"/>`, 5)
+	suite.NotNil(err)
+}
+
+func (suite *SoapTestSuite) TestBuildSoapEnvelope() {
+	wsdl, err := ParseWsdl([]byte(fmt.Sprintf(testWsdl, "http://localhost")))
+	suite.Require().Nil(err)
+	price, _ := wsdl.GetOperation("GetPrice", "")
+	add, _ := wsdl.GetOperation("Add", "")
+
+	envelope, err := BuildSoapEnvelope(price, `{"Symbol": "A&B", "Tags": ["x", "y"], "Limit": {"@unit": "USD", "#text": 2.5}, "Note": null}`, nil)
+	suite.Nil(err)
+	suite.Contains(string(envelope), `<soap:Body><ns:GetPrice xmlns:ns="urn:stock"><ns:Symbol>A&amp;B</ns:Symbol><ns:Tags>x</ns:Tags><ns:Tags>y</ns:Tags>`+
+		`<ns:Limit unit="USD">2.5</ns:Limit><ns:Note xsi:nil="true"/></ns:GetPrice></soap:Body>`)
+
+	envelope, err = BuildSoapEnvelope(add, `{"b": 2, "a": 1}`, nil)
+	suite.Nil(err)
+	suite.Contains(string(envelope), `<soap:Body><op:Add xmlns:op="urn:calculator"><a>1</a><b>2</b></op:Add></soap:Body>`)
+
+	for _, params := range []string{`{"c": 1}`, `[1]`, `{"a": 1`} {
+		_, err = BuildSoapEnvelope(add, params, nil)
+		suite.NotNil(err, params)
+	}
+	for _, params := range []string{`{"bad name": 1}`, `{"@unit": [1]}`} {
+		_, err = BuildSoapEnvelope(price, params, nil)
+		suite.NotNil(err, params)
+	}
+}
+
+func (suite *SoapTestSuite) TestWsSecurity() {
+	wsdl, err := ParseWsdl([]byte(fmt.Sprintf(testWsdl, "http://localhost")))
+	suite.Require().Nil(err)
+	price, _ := wsdl.GetOperation("GetPrice", SoapVersion12)
+
+	envelope, err := BuildSoapEnvelope(price, "", &WsSecurity{Username: "user", Password: "secret", PasswordType: WsPasswordDigest})
+	suite.Require().Nil(err)
+	suite.Contains(string(envelope), `soap:mustUnderstand="true"`)
+
+	root, err := parseXml(envelope)
+	suite.Require().Nil(err)
+	token := root.child("Header").child("Security").child("UsernameToken")
+	suite.Require().NotNil(token)
+	suite.Equal("user", token.child("Username").text.String())
+
+	nonce, err := base64.StdEncoding.DecodeString(token.child("Nonce").text.String())
+	suite.Require().Nil(err)
+	digest := sha1.Sum([]byte(string(nonce) + token.child("Created").text.String() + "secret"))
+	suite.Equal(base64.StdEncoding.EncodeToString(digest[:]), token.child("Password").text.String())
+
+	_, err = BuildSoapEnvelope(price, "", &WsSecurity{Username: "user", PasswordType: "plain"})
+	suite.NotNil(err)
+}
+
+func (suite *SoapTestSuite) TestSendSoapRequest() {
+	wsdl, err := LoadWsdl(suite.ctx, nil, suite.server.URL+"/stock.wsdl", 5)
+	suite.Require().Nil(err)
+
+	for _, version := range []string{SoapVersion11, SoapVersion12} {
+		price, err := wsdl.GetOperation("GetPrice", version)
+		suite.Require().Nil(err)
+		body, err := SendSoapRequest(suite.ctx, nil, "", 5, nil, price, `{"Symbol": "BLNK"}`, nil, nil)
+		suite.Nil(err)
+		suite.JSONEq(`{"GetPriceResponse": {"Price": {"@currency": "USD", "#text": "1.90"}}}`, string(body))
+	}
+
+	price, _ := wsdl.GetOperation("GetPrice", "")
+	body, err := SendSoapRequest(suite.ctx, nil, "", 5, nil, price, `{"Symbol": "NONE"}`, nil, nil)
+	suite.EqualError(err, "soap fault soap:Client: Unknown symbol")
+
+	fault := map[string]interface{}{}
+	suite.Nil(json.Unmarshal(body, &fault))
+	suite.Equal("Unknown symbol", fault["faultstring"])
+}
+
+func (suite *SoapTestSuite) TestXmlValue() {
+	root, err := parseXml([]byte(`<root xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" id="1">
+		<item>a</item><item>b</item><item>c</item><empty/><missing xsi:nil="true"/>text</root>`))
+	suite.Require().Nil(err)
+
+	value, err := json.Marshal(root.value())
+	suite.Nil(err)
+	suite.JSONEq(`{"@id": "1", "item": ["a", "b", "c"], "empty": "", "missing": null, "#text": "text"}`, string(value))
+
+	_, err = parseXml([]byte(strings.Repeat(" ", 3)))
+	suite.NotNil(err)
+}
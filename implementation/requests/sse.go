@@ -0,0 +1,280 @@
+package requests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/blinkops/blink-http/implementation/transport"
+	"github.com/blinkops/blink-http/plugins/types"
+	"github.com/blinkops/blink-sdk/plugin"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultSseIdleTimeout = 30 * time.Second
+	defaultSseRetry       = time.Second
+)
+
+type SseOptions struct {
+	// MaxEvents stops reading after this many events, 0 reads until a timeout or the condition
+	MaxEvents   int
+	IdleTimeout time.Duration
+	// Until stops reading after an event whose json data matches the expression
+	Until         *Extractor
+	LastEventId   string
+	MaxReconnects int
+}
+
+type SseEvent struct {
+	Id    string      `json:"id,omitempty"`
+	Event string      `json:"event"`
+	Data  interface{} `json:"data"`
+}
+
+// sseMessage is either a dispatched event or a new reconnection time sent by the server
+type sseMessage struct {
+	event *SseEvent
+	raw   []byte
+	retry time.Duration
+}
+
+type sseReader struct {
+	options     *SseOptions
+	events      []SseEvent
+	lastEventId string
+	retry       time.Duration
+	authHeaders []string
+}
+
+// sseConnectionError is returned when the stream couldn't be opened at all, which is retried on reconnects
+type sseConnectionError struct {
+	err error
+}
+
+func (e *sseConnectionError) Error() string {
+	return fmt.Sprintf("failed to open the event stream, error: %v", e.err)
+}
+
+// ReadServerSentEvents opens a text/event-stream with the connections' auth and returns the received events as a json array.
+// the stream is resumed from the last event id when it ends or a reconnect fails, until a stop condition is reached or
+// the reconnects run out. event data that is json is returned as a json value, other data as a string
+func ReadServerSentEvents(ctx *plugin.ActionContext, plugin types.Plugin, urlString string, timeout int32, headers http.Header, options *SseOptions) ([]byte, error) {
+	transportConnection, err := getTransportConnection(ctx.GetAllConnections())
+	if err != nil {
+		return nil, err
+	}
+	httpTransport, err := transport.GetTransport(transportConnection)
+	if err != nil {
+		return nil, err
+	}
+	reader := &sseReader{options: options, events: []SseEvent{}, lastEventId: options.LastEventId, retry: defaultSseRetry}
+
+	// the client has no timeout, since the stream is read for as long as events arrive. the deadline of the
+	// context bounds the whole read instead, reconnects included
+	client := &http.Client{
+		Transport: httpTransport,
+		CheckRedirect: newRedirectPolicy(ctx.GetAllConnections(), plugin, &RequestOptions{}, func() []string {
+			return reader.authHeaders
+		}),
+	}
+
+	readContext := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		readContext, cancel = context.WithTimeout(readContext, time.Second*time.Duration(timeout))
+		defer cancel()
+	}
+
+	for reconnects := 0; ; reconnects++ {
+		stopped, err := reader.readStream(readContext, ctx, plugin, client, urlString, headers)
+		if _, ok := err.(*sseConnectionError); ok && reconnects > 0 {
+			// a failed reconnect counts as one of the reconnects, the stream is opened again after the retry time
+			err = nil
+		}
+		if err != nil {
+			if len(reader.events) == 0 {
+				return nil, err
+			}
+			events, _ := json.Marshal(reader.events)
+			return events, err
+		}
+		if stopped || reconnects >= options.MaxReconnects {
+			break
+		}
+
+		select {
+		case <-time.After(reader.retry):
+		case <-readContext.Done():
+			return json.Marshal(reader.events)
+		}
+	}
+	return json.Marshal(reader.events)
+}
+
+// readStream reads a single connection until readContext is done, it returns true when a stop condition is reached
+// and false when the stream ended
+func (r *sseReader) readStream(readContext context.Context, ctx *plugin.ActionContext, plugin types.Plugin, client *http.Client, urlString string, headers http.Header) (bool, error) {
+	streamContext, cancel := context.WithCancel(readContext)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(streamContext, http.MethodGet, urlString, nil)
+	if err != nil {
+		return false, err
+	}
+	for name, values := range headers {
+		for _, value := range values {
+			request.Header.Add(name, value)
+		}
+	}
+	request.Header.Set("Accept", "text/event-stream")
+	request.Header.Set("Cache-Control", "no-cache")
+	if r.lastEventId != "" {
+		request.Header.Set("Last-Event-ID", r.lastEventId)
+	}
+	headersBeforeAuth := request.Header.Clone()
+	if err = authenticateRequest(ctx, plugin, request); err != nil {
+		return false, err
+	}
+	r.authHeaders = getAuthHeaders(headersBeforeAuth, request.Header)
+
+	response, err := client.Do(request)
+	if err != nil {
+		return false, &sseConnectionError{err: err}
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	// the server tells the client to stop reconnecting with no content
+	if response.StatusCode == http.StatusNoContent {
+		return true, nil
+	}
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusBadRequest {
+		body, _ := ReadBody(response.Body)
+		return false, fmt.Errorf("failed to open the event stream, status: %v, body: %s", response.StatusCode, string(body))
+	}
+	if mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type")); mediaType != "text/event-stream" {
+		return false, fmt.Errorf("the response is not an event stream, content type: %s", response.Header.Get("Content-Type"))
+	}
+
+	messages := make(chan sseMessage)
+	ended := make(chan struct{})
+	go func() {
+		defer close(ended)
+		parseEventStream(response.Body, streamContext.Done(), messages)
+	}()
+
+	idleTimeout := r.options.IdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultSseIdleTimeout
+	}
+	idle := time.NewTimer(idleTimeout)
+	defer idle.Stop()
+
+	for {
+		select {
+		case message := <-messages:
+			if message.event == nil {
+				r.retry = message.retry
+				continue
+			}
+
+			r.events = append(r.events, *message.event)
+			if message.event.Id != "" {
+				r.lastEventId = message.event.Id
+			}
+			if r.options.MaxEvents > 0 && len(r.events) >= r.options.MaxEvents {
+				return true, nil
+			}
+			if r.options.Until != nil && r.options.Until.Matches(message.raw) {
+				return true, nil
+			}
+
+			if !idle.Stop() {
+				<-idle.C
+			}
+			idle.Reset(idleTimeout)
+		case <-ended:
+			return false, nil
+		case <-idle.C:
+			return true, nil
+		case <-readContext.Done():
+			return true, nil
+		}
+	}
+}
+
+// parseEventStream dispatches the events of the stream as described in the html living standard, until the stream ends or done is closed
+func parseEventStream(body io.Reader, done <-chan struct{}, messages chan<- sseMessage) {
+	send := func(message sseMessage) bool {
+		select {
+		case messages <- message:
+			return true
+		case <-done:
+			return false
+		}
+	}
+
+	reader := bufio.NewReader(body)
+	var eventType, lastEventId string
+	var data strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" {
+			if data.Len() > 0 {
+				raw := strings.TrimSuffix(data.String(), "\n")
+				if eventType == "" {
+					eventType = "message"
+				}
+				if !send(sseMessage{event: &SseEvent{Id: lastEventId, Event: eventType, Data: decodeEventData(raw)}, raw: []byte(raw)}) {
+					return
+				}
+			}
+			eventType = ""
+			data.Reset()
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+		if index := strings.Index(line, ":"); index >= 0 {
+			field, value = line[:index], strings.TrimPrefix(line[index+1:], " ")
+		}
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data.WriteString(value)
+			data.WriteString("\n")
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				lastEventId = value
+			}
+		case "retry":
+			if milliseconds, err := strconv.Atoi(value); err == nil && milliseconds >= 0 {
+				if !send(sseMessage{retry: time.Millisecond * time.Duration(milliseconds)}) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func decodeEventData(data string) interface{} {
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(data), &decoded); err == nil {
+		return decoded
+	}
+	return data
+}
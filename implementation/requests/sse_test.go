@@ -0,0 +1,189 @@
+package requests
+
+import (
+	"fmt"
+	"github.com/blinkops/blink-http/consts"
+	"github.com/blinkops/blink-sdk/plugin"
+	"github.com/blinkops/blink-sdk/plugin/connections"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type SseTestSuite struct {
+	suite.Suite
+}
+
+func TestSseTestSuite(t *testing.T) {
+	suite.Run(t, new(SseTestSuite))
+}
+
+func (suite *SseTestSuite) TestParseEventStream() {
+	stream := ": comment\r\n" +
+		"retry: 250\n" +
+		"data: first\n\n" +
+		"event: update\nid: 1\ndata: {\"status\":\ndata:  \"running\"}\n\n" +
+		"id\n\n" +
+		"data\n\n" +
+		"event: ignored\n\n" +
+		"data: incomplete"
+
+	messages := make(chan sseMessage)
+	go func() {
+		parseEventStream(strings.NewReader(stream), nil, messages)
+		close(messages)
+	}()
+
+	var received []sseMessage
+	for message := range messages {
+		received = append(received, message)
+	}
+
+	suite.Require().Len(received, 4)
+	suite.Equal(250*time.Millisecond, received[0].retry)
+	suite.Equal(SseEvent{Event: "message", Data: "first"}, *received[1].event)
+	suite.Equal(SseEvent{Id: "1", Event: "update", Data: map[string]interface{}{"status": "running"}}, *received[2].event)
+	suite.Equal(`{"status":`+"\n"+` "running"}`, string(received[2].raw))
+	// an empty id field resets the last event id and an empty data field is still dispatched
+	suite.Equal(SseEvent{Event: "message", Data: ""}, *received[3].event)
+}
+
+func (suite *SseTestSuite) TestReadServerSentEvents() {
+	var lastEventIds []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		lastEventIds = append(lastEventIds, r.Header.Get("Last-Event-ID"))
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+
+		switch r.Header.Get("Last-Event-ID") {
+		case "":
+			// the stream drops after two events, so the client resumes it
+			_, _ = fmt.Fprint(w, "retry: 10\n\nid: 1\ndata: {\"progress\": 50}\n\nid: 2\ndata: {\"progress\": 75}\n\n")
+		case "2":
+			_, _ = fmt.Fprint(w, "id: 3\nevent: done\ndata: {\"progress\": 100}\n\n")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	ctx := plugin.NewActionContext(nil, map[string]*connections.ConnectionInstance{
+		consts.BearerAuthKey: {Data: map[string]string{consts.RequestUrlKey: server.URL, consts.TokenKey: "token"}},
+	})
+
+	until, err := NewExtractor("progress == `100`")
+	suite.Require().Nil(err)
+	body, err := ReadServerSentEvents(ctx, nil, server.URL+"/events", 5, nil, &SseOptions{Until: until, MaxReconnects: 3})
+	suite.Nil(err)
+	suite.JSONEq(`[{"id": "1", "event": "message", "data": {"progress": 50}}, {"id": "2", "event": "message", "data": {"progress": 75}}, {"id": "3", "event": "done", "data": {"progress": 100}}]`, string(body))
+	suite.Equal([]string{"", "2"}, lastEventIds)
+
+	// the stream stays open after the last event, so the idle timeout ends it
+	body, err = ReadServerSentEvents(ctx, nil, server.URL+"/events", 5, nil, &SseOptions{LastEventId: "2", IdleTimeout: 100 * time.Millisecond})
+	suite.Nil(err)
+	suite.JSONEq(`[{"id": "3", "event": "done", "data": {"progress": 100}}]`, string(body))
+
+	body, err = ReadServerSentEvents(ctx, nil, server.URL+"/events", 5, nil, &SseOptions{MaxEvents: 1})
+	suite.Nil(err)
+	suite.JSONEq(`[{"id": "1", "event": "message", "data": {"progress": 50}}]`, string(body))
+
+	body, err = ReadServerSentEvents(ctx, nil, server.URL+"/events", 5, nil, &SseOptions{LastEventId: "3"})
+	suite.Nil(err)
+	suite.JSONEq(`[]`, string(body))
+
+	unauthorized := plugin.NewActionContext(nil, map[string]*connections.ConnectionInstance{
+		consts.BearerAuthKey: {Data: map[string]string{consts.RequestUrlKey: server.URL, consts.TokenKey: "other"}},
+	})
+	_, err = ReadServerSentEvents(unauthorized, nil, server.URL+"/events", 5, nil, &SseOptions{})
+	suite.NotNil(err)
+}
+
+func (suite *SseTestSuite) TestReadServerSentEventsReconnectFailure() {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the connections aren't reused, so the transport doesn't resend the request that fails on its own
+		w.Header().Set("Connection", "close")
+		switch atomic.AddInt32(&attempts, 1) {
+		case 1:
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprint(w, "retry: 10\n\nid: 1\ndata: first\n\n")
+		case 2:
+			conn, _, err := w.(http.Hijacker).Hijack()
+			suite.Require().Nil(err)
+			_ = conn.Close()
+		default:
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprint(w, "id: 2\ndata: second\n\n")
+		}
+	}))
+	defer server.Close()
+
+	ctx := plugin.NewActionContext(nil, map[string]*connections.ConnectionInstance{
+		consts.ApiTokenKey: {Data: map[string]string{consts.RequestUrlKey: server.URL}},
+	})
+
+	// the failed reconnect is retried
+	body, err := ReadServerSentEvents(ctx, nil, server.URL, 5, nil, &SseOptions{MaxReconnects: 2})
+	suite.Nil(err)
+	suite.JSONEq(`[{"id": "1", "event": "message", "data": "first"}, {"id": "2", "event": "message", "data": "second"}]`, string(body))
+	suite.Equal(int32(3), atomic.LoadInt32(&attempts))
+
+	// and counts as one of the reconnects
+	atomic.StoreInt32(&attempts, 0)
+	body, err = ReadServerSentEvents(ctx, nil, server.URL, 5, nil, &SseOptions{MaxReconnects: 1})
+	suite.Nil(err)
+	suite.JSONEq(`[{"id": "1", "event": "message", "data": "first"}]`, string(body))
+	suite.Equal(int32(2), atomic.LoadInt32(&attempts))
+}
+
+func (suite *SseTestSuite) TestReadServerSentEventsTimeout() {
+	// the server never sends the response headers
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	ctx := plugin.NewActionContext(nil, map[string]*connections.ConnectionInstance{
+		consts.ApiTokenKey: {Data: map[string]string{consts.RequestUrlKey: server.URL}},
+	})
+	start := time.Now()
+	_, err := ReadServerSentEvents(ctx, nil, server.URL, 1, nil, &SseOptions{})
+	suite.NotNil(err)
+	suite.Less(int64(time.Since(start)), int64(3*time.Second))
+}
+
+func (suite *SseTestSuite) TestReadServerSentEventsRedirect() {
+	var receivedHeaders http.Header
+	external := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedHeaders = r.Header.Clone()
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "data: redirected\n\n")
+	}))
+	defer external.Close()
+
+	allowed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, external.URL+"/events", http.StatusFound)
+	}))
+	defer allowed.Close()
+
+	ctx := plugin.NewActionContext(nil, map[string]*connections.ConnectionInstance{
+		consts.ApiTokenKey: {Data: map[string]string{consts.RequestUrlKey: allowed.URL, "Header 1": "X-Secret", "Value 1": "value"}},
+	})
+	body, err := ReadServerSentEvents(ctx, nil, allowed.URL+"/events", 5, nil, &SseOptions{})
+	suite.Nil(err)
+	suite.JSONEq(`[{"event": "message", "data": "redirected"}]`, string(body))
+
+	// the redirect leaves the connection's url, so the connection's headers are removed
+	suite.Equal("", receivedHeaders.Get("X-Secret"))
+	suite.Equal("text/event-stream", receivedHeaders.Get("Accept"))
+}
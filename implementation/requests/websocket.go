@@ -0,0 +1,242 @@
+package requests
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/blinkops/blink-http/implementation/transport"
+	"github.com/blinkops/blink-http/plugins/types"
+	"github.com/blinkops/blink-sdk/plugin"
+	"github.com/gorilla/websocket"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const DefaultWebSocketReceiveTimeout = 10 * time.Second
+
+type WebSocketOptions struct {
+	Messages [][]byte
+	// MaxMessages stops receiving after this many messages, 0 receives until the timeout or the condition
+	MaxMessages    int
+	ReceiveTimeout time.Duration
+	// Until stops receiving after a json message that matches the expression
+	Until        *Extractor
+	Subprotocols []string
+}
+
+// ParseWebSocketMessages returns the messages to send. a json array sends each of its elements as a message,
+// strings as is and other values as json, anything else is sent as a single message
+func ParseWebSocketMessages(value string) ([][]byte, error) {
+	trimmed := strings.TrimSpace(value)
+	if trimmed == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(trimmed, "[") {
+		return [][]byte{[]byte(value)}, nil
+	}
+
+	var elements []json.RawMessage
+	if err := json.Unmarshal([]byte(trimmed), &elements); err != nil {
+		return nil, fmt.Errorf("invalid messages json array, error: %v", err)
+	}
+
+	messages := make([][]byte, 0, len(elements))
+	for _, element := range elements {
+		var text string
+		if err := json.Unmarshal(element, &text); err == nil {
+			messages = append(messages, []byte(text))
+			continue
+		}
+		messages = append(messages, element)
+	}
+	return messages, nil
+}
+
+// SendWebSocketMessages connects with the connections' auth, sends the messages and returns the received messages as a json array.
+// json messages are returned as json values, other text messages as strings and binary messages as base64 strings
+func SendWebSocketMessages(ctx *plugin.ActionContext, plugin types.Plugin, urlString string, timeout int32, headers http.Header, options *WebSocketOptions) ([]byte, error) {
+	dialUrl, requestUrl, err := getWebSocketUrls(urlString)
+	if err != nil {
+		return nil, err
+	}
+
+	// the handshake is an http request, so it's authenticated like any other request
+	request, err := http.NewRequest(http.MethodGet, requestUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range headers {
+		for _, value := range values {
+			request.Header.Add(name, value)
+		}
+	}
+	if err = authenticateRequest(ctx, plugin, request); err != nil {
+		return nil, err
+	}
+
+	transportConnection, err := getTransportConnection(ctx.GetAllConnections())
+	if err != nil {
+		return nil, err
+	}
+	httpTransport, err := transport.GetTransport(transportConnection)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &websocket.Dialer{
+		Proxy:            httpTransport.Proxy,
+		TLSClientConfig:  httpTransport.TLSClientConfig,
+		HandshakeTimeout: time.Second * time.Duration(timeout),
+		Subprotocols:     options.Subprotocols,
+	}
+	if httpTransport.Proxy != nil {
+		proxyUrl, err := httpTransport.Proxy(request)
+		if err != nil {
+			return nil, err
+		}
+		// the websocket dialer only supports http and socks5 proxies, so the tunnel through https proxies is opened here
+		if proxyUrl != nil && proxyUrl.Scheme == "https" {
+			dialer.Proxy = nil
+			dialer.NetDialContext = dialHttpsProxy(proxyUrl, httpTransport.TLSClientConfig)
+		}
+	}
+	conn, response, err := dialer.Dial(dialUrl, request.Header)
+	if err != nil {
+		if response != nil {
+			body, _ := ioutil.ReadAll(response.Body)
+			return body, fmt.Errorf("websocket handshake failed, status: %v", response.StatusCode)
+		}
+		return nil, fmt.Errorf("failed to connect to %s, error: %v", urlString, err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	for _, message := range options.Messages {
+		if err = conn.WriteMessage(websocket.TextMessage, message); err != nil {
+			return nil, fmt.Errorf("failed to send message, error: %v", err)
+		}
+	}
+
+	receiveTimeout := options.ReceiveTimeout
+	if receiveTimeout <= 0 {
+		receiveTimeout = DefaultWebSocketReceiveTimeout
+	}
+	if err = conn.SetReadDeadline(time.Now().Add(receiveTimeout)); err != nil {
+		return nil, err
+	}
+
+	messages := []interface{}{}
+	for options.MaxMessages == 0 || len(messages) < options.MaxMessages {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				break
+			}
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				break
+			}
+			received, _ := json.Marshal(messages)
+			return received, fmt.Errorf("failed to receive message, error: %v", err)
+		}
+
+		messages = append(messages, decodeWebSocketMessage(messageType, data))
+		if options.Until != nil && options.Until.Matches(data) {
+			break
+		}
+	}
+
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	return json.Marshal(messages)
+}
+
+// dialHttpsProxy opens a tunnel to the address with a CONNECT request to the proxy, over a tls connection
+// that verifies the proxy with the transport's tls config, the way http.Transport does
+func dialHttpsProxy(proxyUrl *url.URL, tlsConfig *tls.Config) func(ctx context.Context, network string, address string) (net.Conn, error) {
+	return func(ctx context.Context, network string, address string) (net.Conn, error) {
+		proxyAddress := proxyUrl.Host
+		if proxyUrl.Port() == "" {
+			proxyAddress = net.JoinHostPort(proxyUrl.Hostname(), "443")
+		}
+
+		config := &tls.Config{}
+		if tlsConfig != nil {
+			config = tlsConfig.Clone()
+		}
+		config.ServerName = proxyUrl.Hostname()
+		config.NextProtos = nil
+
+		conn, err := (&tls.Dialer{Config: config}).DialContext(ctx, network, proxyAddress)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to the proxy, error: %v", err)
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			_ = conn.SetDeadline(deadline)
+		}
+
+		connect := &http.Request{
+			Method: http.MethodConnect,
+			URL:    &url.URL{Opaque: address},
+			Host:   address,
+			Header: http.Header{},
+		}
+		if proxyUrl.User != nil {
+			password, _ := proxyUrl.User.Password()
+			connect.Header.Set("Proxy-Authorization", "Basic "+basicAuth(proxyUrl.User.Username(), password))
+		}
+		if err = connect.Write(conn); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("failed to send the request to the proxy, error: %v", err)
+		}
+
+		response, err := http.ReadResponse(bufio.NewReader(conn), connect)
+		if err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("failed to read the response of the proxy, error: %v", err)
+		}
+		// the body of a successful CONNECT response is the tunnel itself, so it's not read or closed
+		if response.StatusCode != http.StatusOK {
+			_ = conn.Close()
+			return nil, fmt.Errorf("the proxy refused to connect to %s, status: %v", address, response.StatusCode)
+		}
+
+		_ = conn.SetDeadline(time.Time{})
+		return conn, nil
+	}
+}
+
+// getWebSocketUrls returns the ws url to dial and the http url the connections validate and sign
+func getWebSocketUrls(urlString string) (string, string, error) {
+	parsedUrl, err := url.Parse(urlString)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse request url, error: %v", err)
+	}
+
+	dialUrl, requestUrl := *parsedUrl, *parsedUrl
+	switch strings.ToLower(parsedUrl.Scheme) {
+	case "ws", "http":
+		dialUrl.Scheme, requestUrl.Scheme = "ws", "http"
+	case "wss", "https":
+		dialUrl.Scheme, requestUrl.Scheme = "wss", "https"
+	default:
+		return "", "", fmt.Errorf("invalid websocket url scheme: %s, must be ws or wss", parsedUrl.Scheme)
+	}
+	return dialUrl.String(), requestUrl.String(), nil
+}
+
+func decodeWebSocketMessage(messageType int, data []byte) interface{} {
+	if messageType == websocket.BinaryMessage {
+		return base64.StdEncoding.EncodeToString(data)
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err == nil {
+		return decoded
+	}
+	return string(data)
+}
@@ -0,0 +1,182 @@
+package requests
+
+import (
+	"encoding/pem"
+	"github.com/blinkops/blink-http/consts"
+	"github.com/blinkops/blink-sdk/plugin"
+	"github.com/blinkops/blink-sdk/plugin/connections"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/suite"
+)
+
+type WebSocketTestSuite struct {
+	suite.Suite
+}
+
+func TestWebSocketTestSuite(t *testing.T) {
+	suite.Run(t, new(WebSocketTestSuite))
+}
+
+func (suite *WebSocketTestSuite) TestParseWebSocketMessages() {
+	messages, err := ParseWebSocketMessages(`["ping", {"type": "subscribe", "channel": "alerts"}]`)
+	suite.Nil(err)
+	suite.Equal([][]byte{[]byte("ping"), []byte(`{"type": "subscribe", "channel": "alerts"}`)}, messages)
+
+	messages, err = ParseWebSocketMessages(`{"type": "ping"}`)
+	suite.Nil(err)
+	suite.Equal([][]byte{[]byte(`{"type": "ping"}`)}, messages)
+
+	messages, err = ParseWebSocketMessages("  ")
+	suite.Nil(err)
+	suite.Nil(messages)
+
+	_, err = ParseWebSocketMessages(`["ping"`)
+	suite.NotNil(err)
+}
+
+func (suite *WebSocketTestSuite) TestSendWebSocketMessages() {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		for _, response := range []string{`{"type": "ack"}`, "progress", `{"type": "done", "request": ` + string(message) + `}`, `{"type": "late"}`} {
+			if err = conn.WriteMessage(websocket.TextMessage, []byte(response)); err != nil {
+				return
+			}
+		}
+		_ = conn.WriteMessage(websocket.BinaryMessage, []byte{0xff})
+		_, _, _ = conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	wsUrl := "ws" + strings.TrimPrefix(server.URL, "http")
+	ctx := plugin.NewActionContext(nil, map[string]*connections.ConnectionInstance{
+		consts.BearerAuthKey: {Data: map[string]string{consts.RequestUrlKey: server.URL, consts.TokenKey: "token"}},
+	})
+
+	until, err := NewExtractor("type == 'done'")
+	suite.Require().Nil(err)
+	body, err := SendWebSocketMessages(ctx, nil, wsUrl, 5, nil, &WebSocketOptions{
+		Messages: [][]byte{[]byte(`{"id": 1}`)},
+		Until:    until,
+	})
+	suite.Nil(err)
+	suite.JSONEq(`[{"type": "ack"}, "progress", {"type": "done", "request": {"id": 1}}]`, string(body))
+
+	body, err = SendWebSocketMessages(ctx, nil, wsUrl, 5, nil, &WebSocketOptions{
+		Messages:       [][]byte{[]byte("1")},
+		ReceiveTimeout: time.Second,
+	})
+	suite.Nil(err)
+	suite.JSONEq(`[{"type": "ack"}, "progress", {"type": "done", "request": 1}, {"type": "late"}, "/w=="]`, string(body))
+
+	body, err = SendWebSocketMessages(ctx, nil, wsUrl, 5, nil, &WebSocketOptions{Messages: [][]byte{[]byte("1")}, MaxMessages: 1})
+	suite.Nil(err)
+	suite.JSONEq(`[{"type": "ack"}]`, string(body))
+
+	unauthorized := plugin.NewActionContext(nil, map[string]*connections.ConnectionInstance{
+		consts.BearerAuthKey: {Data: map[string]string{consts.RequestUrlKey: server.URL, consts.TokenKey: "other"}},
+	})
+	_, err = SendWebSocketMessages(unauthorized, nil, wsUrl, 5, nil, &WebSocketOptions{})
+	suite.EqualError(err, "websocket handshake failed, status: 401")
+
+	_, err = SendWebSocketMessages(ctx, nil, "ws://example.com/socket", 5, nil, &WebSocketOptions{})
+	suite.NotNil(err)
+}
+
+func (suite *WebSocketTestSuite) TestSendWebSocketMessagesThroughProxy() {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_ = conn.WriteMessage(websocket.TextMessage, []byte("proxied"))
+		_, _, _ = conn.ReadMessage()
+	}))
+	defer server.Close()
+
+	var tunnels []string
+	var lock sync.Mutex
+	connectProxy := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect || r.Header.Get("Proxy-Authorization") != "Basic "+basicAuth("user", "password") {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		lock.Lock()
+		tunnels = append(tunnels, r.Host)
+		lock.Unlock()
+
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		client, buffered, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			_ = target.Close()
+			return
+		}
+		go func() {
+			_, _ = io.Copy(target, buffered)
+			_ = target.Close()
+		}()
+		_, _ = io.Copy(client, target)
+		_ = client.Close()
+	})
+	httpProxy := httptest.NewServer(connectProxy)
+	defer httpProxy.Close()
+	httpsProxy := httptest.NewTLSServer(connectProxy)
+	defer httpsProxy.Close()
+
+	wsUrl := "ws" + strings.TrimPrefix(server.URL, "http")
+	for _, proxy := range []*httptest.Server{httpProxy, httpsProxy} {
+		ctx := plugin.NewActionContext(nil, map[string]*connections.ConnectionInstance{
+			consts.ApiTokenKey: {Data: map[string]string{
+				consts.RequestUrlKey:    server.URL,
+				consts.ProxyUrlKey:      proxy.URL,
+				consts.ProxyUsernameKey: "user",
+				consts.ProxyPasswordKey: "password",
+				consts.TlsCaCertKey:     string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: httpsProxy.Certificate().Raw})),
+			}},
+		})
+		body, err := SendWebSocketMessages(ctx, nil, wsUrl, 5, nil, &WebSocketOptions{MaxMessages: 1})
+		suite.Nil(err, proxy.URL)
+		suite.JSONEq(`["proxied"]`, string(body))
+	}
+	suite.Equal([]string{strings.TrimPrefix(server.URL, "http://"), strings.TrimPrefix(server.URL, "http://")}, tunnels)
+
+	ctx := plugin.NewActionContext(nil, map[string]*connections.ConnectionInstance{
+		consts.ApiTokenKey: {Data: map[string]string{
+			consts.RequestUrlKey: server.URL,
+			consts.ProxyUrlKey:   httpsProxy.URL,
+			consts.TlsCaCertKey:  string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: httpsProxy.Certificate().Raw})),
+		}},
+	})
+	_, err := SendWebSocketMessages(ctx, nil, wsUrl, 5, nil, &WebSocketOptions{MaxMessages: 1})
+	suite.NotNil(err)
+	suite.Contains(err.Error(), "status: 407")
+}
@@ -0,0 +1,235 @@
+package requests
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	wsdlSoap11Namespace = "http://schemas.xmlsoap.org/wsdl/soap/"
+	wsdlSoap12Namespace = "http://schemas.xmlsoap.org/wsdl/soap12/"
+
+	SoapVersion11 = "1.1"
+	SoapVersion12 = "1.2"
+)
+
+type wsdlDefinitions struct {
+	TargetNamespace string     `xml:"targetNamespace,attr"`
+	Attrs           []xml.Attr `xml:",any,attr"`
+	Schemas         []struct {
+		TargetNamespace    string `xml:"targetNamespace,attr"`
+		ElementFormDefault string `xml:"elementFormDefault,attr"`
+	} `xml:"types>schema"`
+	Messages []struct {
+		Name  string `xml:"name,attr"`
+		Parts []struct {
+			Name    string `xml:"name,attr"`
+			Element string `xml:"element,attr"`
+			Type    string `xml:"type,attr"`
+		} `xml:"part"`
+	} `xml:"message"`
+	PortTypes []struct {
+		Name       string `xml:"name,attr"`
+		Operations []struct {
+			Name  string `xml:"name,attr"`
+			Input struct {
+				Message string `xml:"message,attr"`
+			} `xml:"input"`
+		} `xml:"operation"`
+	} `xml:"portType"`
+	Bindings []struct {
+		Name string `xml:"name,attr"`
+		Type string `xml:"type,attr"`
+		Soap []struct {
+			XMLName xml.Name
+			Style   string `xml:"style,attr"`
+		} `xml:"binding"`
+		Operations []struct {
+			Name string `xml:"name,attr"`
+			Soap []struct {
+				SoapAction string `xml:"soapAction,attr"`
+				Style      string `xml:"style,attr"`
+			} `xml:"operation"`
+			InputBody []struct {
+				Namespace string `xml:"namespace,attr"`
+			} `xml:"input>body"`
+		} `xml:"operation"`
+	} `xml:"binding"`
+	Services []struct {
+		Ports []struct {
+			Binding   string `xml:"binding,attr"`
+			Addresses []struct {
+				XMLName  xml.Name
+				Location string `xml:"location,attr"`
+			} `xml:"address"`
+		} `xml:"port"`
+	} `xml:"service"`
+}
+
+// Wsdl holds the soap operations of a WSDL 1.1 document
+type Wsdl struct {
+	Operations []*SoapOperation
+}
+
+type SoapOperation struct {
+	Name        string      `json:"name"`
+	SoapAction  string      `json:"soap_action"`
+	SoapVersion string      `json:"soap_version"`
+	Style       string      `json:"style"`
+	Endpoint    string      `json:"endpoint"`
+	Parts       []*SoapPart `json:"parts"`
+	// namespace is the namespace of the rpc operation element
+	namespace string
+}
+
+type SoapPart struct {
+	Name    string `json:"name"`
+	Element string `json:"element,omitempty"`
+	Type    string `json:"type,omitempty"`
+	// the namespace and local name of the element of document style parts
+	namespace string
+	local     string
+	// qualified child elements are in the namespace of the element
+	qualified bool
+}
+
+// ParseWsdl returns the operations of the document's soap bindings
+func ParseWsdl(data []byte) (*Wsdl, error) {
+	definitions := wsdlDefinitions{}
+	if err := xml.Unmarshal(data, &definitions); err != nil {
+		return nil, fmt.Errorf("invalid wsdl, error: %v", err)
+	}
+
+	// the qualified names of the messages, parts and bindings use the prefixes declared on the definitions
+	prefixes := map[string]string{}
+	for _, attr := range definitions.Attrs {
+		if attr.Name.Space == "xmlns" {
+			prefixes[attr.Name.Local] = attr.Value
+		} else if attr.Name.Space == "" && attr.Name.Local == "xmlns" {
+			prefixes[""] = attr.Value
+		}
+	}
+	resolve := func(qualifiedName string) (string, string) {
+		prefix, local := "", qualifiedName
+		if index := strings.Index(qualifiedName, ":"); index >= 0 {
+			prefix, local = qualifiedName[:index], qualifiedName[index+1:]
+		}
+		return prefixes[prefix], local
+	}
+	localName := func(qualifiedName string) string {
+		_, local := resolve(qualifiedName)
+		return local
+	}
+
+	qualifiedSchemas := map[string]bool{}
+	for _, schema := range definitions.Schemas {
+		qualifiedSchemas[schema.TargetNamespace] = schema.ElementFormDefault == "qualified"
+	}
+
+	endpoints := map[string]string{}
+	for _, service := range definitions.Services {
+		for _, port := range service.Ports {
+			for _, address := range port.Addresses {
+				if address.XMLName.Space == wsdlSoap11Namespace || address.XMLName.Space == wsdlSoap12Namespace {
+					endpoints[localName(port.Binding)] = address.Location
+				}
+			}
+		}
+	}
+
+	wsdl := &Wsdl{}
+	for _, binding := range definitions.Bindings {
+		version, bindingStyle := "", "document"
+		for _, soapBinding := range binding.Soap {
+			switch soapBinding.XMLName.Space {
+			case wsdlSoap11Namespace:
+				version = SoapVersion11
+			case wsdlSoap12Namespace:
+				version = SoapVersion12
+			}
+			if soapBinding.Style != "" {
+				bindingStyle = soapBinding.Style
+			}
+		}
+		// http bindings have no soap operations
+		if version == "" {
+			continue
+		}
+
+		for _, bindingOperation := range binding.Operations {
+			operation := &SoapOperation{
+				Name:        bindingOperation.Name,
+				SoapVersion: version,
+				Style:       bindingStyle,
+				Endpoint:    endpoints[binding.Name],
+				Parts:       []*SoapPart{},
+				namespace:   definitions.TargetNamespace,
+			}
+			for _, soapOperation := range bindingOperation.Soap {
+				operation.SoapAction = soapOperation.SoapAction
+				if soapOperation.Style != "" {
+					operation.Style = soapOperation.Style
+				}
+			}
+			for _, body := range bindingOperation.InputBody {
+				if body.Namespace != "" {
+					operation.namespace = body.Namespace
+				}
+			}
+
+			inputMessage := ""
+			for _, portType := range definitions.PortTypes {
+				if portType.Name != localName(binding.Type) {
+					continue
+				}
+				for _, portTypeOperation := range portType.Operations {
+					if portTypeOperation.Name == operation.Name {
+						inputMessage = localName(portTypeOperation.Input.Message)
+					}
+				}
+			}
+			for _, message := range definitions.Messages {
+				if message.Name != inputMessage {
+					continue
+				}
+				for _, part := range message.Parts {
+					soapPart := &SoapPart{Name: part.Name, Element: part.Element, Type: part.Type}
+					if part.Element != "" {
+						soapPart.namespace, soapPart.local = resolve(part.Element)
+						soapPart.qualified = qualifiedSchemas[soapPart.namespace]
+					}
+					operation.Parts = append(operation.Parts, soapPart)
+				}
+			}
+
+			wsdl.Operations = append(wsdl.Operations, operation)
+		}
+	}
+
+	if len(wsdl.Operations) == 0 {
+		return nil, errors.New("the wsdl does not contain soap operations")
+	}
+	sort.SliceStable(wsdl.Operations, func(i, j int) bool {
+		if wsdl.Operations[i].Name != wsdl.Operations[j].Name {
+			return wsdl.Operations[i].Name < wsdl.Operations[j].Name
+		}
+		return wsdl.Operations[i].SoapVersion < wsdl.Operations[j].SoapVersion
+	})
+	return wsdl, nil
+}
+
+// GetOperation returns the operation of the soap version, any version is accepted when it's empty and 1.1 is preferred
+func (w *Wsdl) GetOperation(name string, version string) (*SoapOperation, error) {
+	for _, operation := range w.Operations {
+		if operation.Name == name && (version == "" || operation.SoapVersion == version) {
+			return operation, nil
+		}
+	}
+	if version != "" {
+		return nil, fmt.Errorf("the wsdl does not contain a soap %s operation named %s", version, name)
+	}
+	return nil, fmt.Errorf("the wsdl does not contain an operation named %s", name)
+}
@@ -0,0 +1,118 @@
+package requests
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	xmlAttributePrefix = "@"
+	xmlTextKey         = "#text"
+	xmlSchemaInstance  = "http://www.w3.org/2001/XMLSchema-instance"
+)
+
+// xmlNode is a parsed xml element, the names keep their namespace uri
+type xmlNode struct {
+	name     xml.Name
+	attrs    []xml.Attr
+	children []*xmlNode
+	text     strings.Builder
+}
+
+// parseXml returns the root element of the document
+func parseXml(data []byte) (*xmlNode, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// the declared charset is ignored, the body is expected to be utf-8 (or ascii compatible)
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	var stack []*xmlNode
+	var root *xmlNode
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid xml, error: %v", err)
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: token.Name, attrs: token.Attr}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, node)
+			} else if root == nil {
+				root = node
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(token)
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, errors.New("invalid xml, the document has no root element")
+	}
+	return root, nil
+}
+
+// child returns the first child element with the local name
+func (n *xmlNode) child(local string) *xmlNode {
+	for _, child := range n.children {
+		if child.name.Local == local {
+			return child
+		}
+	}
+	return nil
+}
+
+// value converts the element to json values: elements are objects keyed by their local name, attributes are
+// "@name" keys, text next to attributes or child elements is the "#text" key, elements with only text are strings,
+// repeated elements are arrays and xsi:nil elements are null. namespace declarations are dropped.
+func (n *xmlNode) value() interface{} {
+	object := map[string]interface{}{}
+	for _, attr := range n.attrs {
+		if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+			continue
+		}
+		if attr.Name.Space == xmlSchemaInstance && attr.Name.Local == "nil" && attr.Value == "true" {
+			return nil
+		}
+		object[xmlAttributePrefix+attr.Name.Local] = attr.Value
+	}
+
+	for _, child := range n.children {
+		childValue := child.value()
+		existing, ok := object[child.name.Local]
+		if !ok {
+			object[child.name.Local] = childValue
+			continue
+		}
+		// element values are never arrays, so an array is always a repeated element
+		if array, ok := existing.([]interface{}); ok {
+			object[child.name.Local] = append(array, childValue)
+		} else {
+			object[child.name.Local] = []interface{}{existing, childValue}
+		}
+	}
+
+	text := strings.TrimSpace(n.text.String())
+	if len(object) == 0 {
+		return text
+	}
+	if text != "" {
+		object[xmlTextKey] = text
+	}
+	return object
+}
@@ -0,0 +1,169 @@
+package transport
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Pool settings, shared by every transport in the registry. they can be tuned with environment variables.
+const (
+	MaxIdleConnsEnvVar        = "BLINK_HTTP_MAX_IDLE_CONNS"
+	MaxIdleConnsPerHostEnvVar = "BLINK_HTTP_MAX_IDLE_CONNS_PER_HOST"
+	MaxConnsPerHostEnvVar     = "BLINK_HTTP_MAX_CONNS_PER_HOST"
+	IdleConnTimeoutEnvVar     = "BLINK_HTTP_IDLE_CONN_TIMEOUT_SECONDS"
+	KeepAliveEnvVar           = "BLINK_HTTP_KEEP_ALIVE_SECONDS"
+	DisableKeepAlivesEnvVar   = "BLINK_HTTP_DISABLE_KEEP_ALIVES"
+	DisableHttp2EnvVar        = "BLINK_HTTP_DISABLE_HTTP2"
+	MaxTransportsEnvVar       = "BLINK_HTTP_MAX_TRANSPORTS"
+)
+
+type PoolSettings struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+	KeepAlive           time.Duration
+	DisableKeepAlives   bool
+	DisableHttp2        bool
+	// MaxTransports bounds the registry, the least recently used transport is closed when it's full
+	MaxTransports int
+}
+
+// transportRegistry keeps the transports of the recently used settings, so rotated certificates or proxies
+// don't keep their transports and idle connections for the life of the process
+type transportRegistry struct {
+	lock       sync.Mutex
+	maxSize    int
+	transports map[string]*list.Element
+	// recent holds the registry entries, the most recently used first
+	recent *list.List
+}
+
+type registryEntry struct {
+	key       string
+	transport *http.Transport
+}
+
+var (
+	poolSettings = loadPoolSettings()
+
+	registry = newTransportRegistry(poolSettings.MaxTransports)
+)
+
+func loadPoolSettings() PoolSettings {
+	return PoolSettings{
+		MaxIdleConns:        getIntEnv(MaxIdleConnsEnvVar, 100),
+		MaxIdleConnsPerHost: getIntEnv(MaxIdleConnsPerHostEnvVar, 10),
+		MaxConnsPerHost:     getIntEnv(MaxConnsPerHostEnvVar, 0),
+		IdleConnTimeout:     time.Duration(getIntEnv(IdleConnTimeoutEnvVar, 90)) * time.Second,
+		KeepAlive:           time.Duration(getIntEnv(KeepAliveEnvVar, 30)) * time.Second,
+		DisableKeepAlives:   getBoolEnv(DisableKeepAlivesEnvVar),
+		DisableHttp2:        getBoolEnv(DisableHttp2EnvVar),
+		MaxTransports:       getIntEnv(MaxTransportsEnvVar, 64),
+	}
+}
+
+func getIntEnv(name string, defaultValue int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return defaultValue
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 0 {
+		log.Warnf("invalid %s: %s, using the default: %d", name, value, defaultValue)
+		return defaultValue
+	}
+	return number
+}
+
+func getBoolEnv(name string) bool {
+	value, err := strconv.ParseBool(os.Getenv(name))
+	return err == nil && value
+}
+
+// GetTransport returns the shared transport for the connection's TLS and proxy settings, so connections
+// to the same hosts are reused across requests. connections with the same settings share a transport.
+func GetTransport(conn map[string]string) (*http.Transport, error) {
+	return registry.get(getRegistryKey(conn), func() (*http.Transport, error) {
+		return NewTransport(conn)
+	})
+}
+
+func newTransportRegistry(maxSize int) *transportRegistry {
+	if maxSize < 1 {
+		maxSize = 1
+	}
+	return &transportRegistry{maxSize: maxSize, transports: map[string]*list.Element{}, recent: list.New()}
+}
+
+func (r *transportRegistry) get(key string, newTransport func() (*http.Transport, error)) (*http.Transport, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if element, ok := r.transports[key]; ok {
+		r.recent.MoveToFront(element)
+		return element.Value.(*registryEntry).transport, nil
+	}
+
+	transport, err := newTransport()
+	if err != nil {
+		return nil, err
+	}
+	r.transports[key] = r.recent.PushFront(&registryEntry{key: key, transport: transport})
+
+	for r.recent.Len() > r.maxSize {
+		oldest := r.recent.Remove(r.recent.Back()).(*registryEntry)
+		delete(r.transports, oldest.key)
+		// requests in flight still complete, their connections are closed once they are idle for IdleConnTimeout
+		oldest.transport.CloseIdleConnections()
+	}
+	return transport, nil
+}
+
+// getRegistryKey hashes the transport settings, the settings include secrets which shouldn't be kept as is
+func getRegistryKey(conn map[string]string) string {
+	var keys []string
+	for key, value := range conn {
+		if transportKeys[key] && value != "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	for _, key := range keys {
+		hash.Write([]byte(key))
+		hash.Write([]byte{0})
+		hash.Write([]byte(conn[key]))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func applyPoolSettings(transport *http.Transport, settings PoolSettings) {
+	transport.MaxIdleConns = settings.MaxIdleConns
+	transport.MaxIdleConnsPerHost = settings.MaxIdleConnsPerHost
+	transport.MaxConnsPerHost = settings.MaxConnsPerHost
+	transport.IdleConnTimeout = settings.IdleConnTimeout
+	transport.DisableKeepAlives = settings.DisableKeepAlives
+	transport.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: settings.KeepAlive,
+	}).DialContext
+
+	transport.ForceAttemptHTTP2 = !settings.DisableHttp2
+	if settings.DisableHttp2 {
+		// a non nil empty map disables http/2
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+}
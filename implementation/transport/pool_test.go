@@ -0,0 +1,102 @@
+package transport
+
+import (
+	"github.com/blinkops/blink-http/consts"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type PoolTestSuite struct {
+	suite.Suite
+}
+
+func TestPoolTestSuite(t *testing.T) {
+	suite.Run(t, new(PoolTestSuite))
+}
+
+func (suite *PoolTestSuite) TestGetTransport() {
+	first, err := GetTransport(map[string]string{consts.ProxyUrlKey: "http://proxy.corp:3128", "Token": "a"})
+	suite.Nil(err)
+
+	// attributes that don't configure the transport don't matter
+	second, err := GetTransport(map[string]string{consts.ProxyUrlKey: "http://proxy.corp:3128", "Token": "b"})
+	suite.Nil(err)
+	suite.True(first == second)
+
+	other, err := GetTransport(map[string]string{consts.ProxyUrlKey: "http://other.corp:3128"})
+	suite.Nil(err)
+	suite.False(first == other)
+
+	defaultTransport, err := GetTransport(nil)
+	suite.Nil(err)
+	sameDefault, err := GetTransport(map[string]string{consts.TlsServerNameKey: ""})
+	suite.Nil(err)
+	suite.True(defaultTransport == sameDefault)
+
+	_, err = GetTransport(map[string]string{consts.TlsMinVersionKey: "2.0"})
+	suite.NotNil(err)
+}
+
+func (suite *PoolTestSuite) TestRegistryEviction() {
+	var closed int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateClosed {
+			atomic.AddInt32(&closed, 1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	lru := newTransportRegistry(2)
+	newTransport := func() (*http.Transport, error) {
+		return NewTransport(nil)
+	}
+
+	first, err := lru.get("first", newTransport)
+	suite.Require().Nil(err)
+	response, err := (&http.Client{Transport: first}).Get(server.URL)
+	suite.Require().Nil(err)
+	_ = response.Body.Close()
+
+	second, _ := lru.get("second", newTransport)
+	sameFirst, _ := lru.get("first", newTransport)
+	suite.True(first == sameFirst)
+
+	// second is the least recently used, so it's evicted rather than first
+	_, _ = lru.get("third", newTransport)
+	otherSecond, _ := lru.get("second", newTransport)
+	suite.False(second == otherSecond)
+	suite.Equal(2, lru.recent.Len())
+	suite.Len(lru.transports, 2)
+
+	// adding second evicted first, which closes its idle connection to the server
+	suite.Eventually(func() bool {
+		return atomic.LoadInt32(&closed) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func (suite *PoolTestSuite) TestApplyPoolSettings() {
+	transport := &http.Transport{}
+	applyPoolSettings(transport, PoolSettings{
+		MaxIdleConns:        50,
+		MaxIdleConnsPerHost: 5,
+		MaxConnsPerHost:     20,
+		IdleConnTimeout:     time.Minute,
+		KeepAlive:           time.Second,
+		DisableHttp2:        true,
+	})
+	suite.Equal(50, transport.MaxIdleConns)
+	suite.Equal(5, transport.MaxIdleConnsPerHost)
+	suite.Equal(20, transport.MaxConnsPerHost)
+	suite.Equal(time.Minute, transport.IdleConnTimeout)
+	suite.False(transport.ForceAttemptHTTP2)
+	suite.NotNil(transport.TLSNextProto)
+	suite.NotNil(transport.DialContext)
+}
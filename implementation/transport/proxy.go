@@ -0,0 +1,107 @@
+package transport
+
+import (
+	"fmt"
+	"github.com/blinkops/blink-http/consts"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var proxySchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"socks5": true,
+}
+
+// getProxy returns nil when the connection has no proxy, in which case the transport keeps its default proxy
+func getProxy(conn map[string]string) (func(*http.Request) (*url.URL, error), error) {
+	proxyString := strings.TrimSpace(conn[consts.ProxyUrlKey])
+	if proxyString == "" {
+		return nil, nil
+	}
+
+	proxyUrl, err := url.Parse(proxyString)
+	if err != nil || proxyUrl.Host == "" {
+		return nil, fmt.Errorf("invalid %s: %s, expected scheme://host:port", consts.ProxyUrlKey, proxyString)
+	}
+	if !proxySchemes[proxyUrl.Scheme] {
+		return nil, fmt.Errorf("invalid %s scheme: %s, must be one of http, https, socks5", consts.ProxyUrlKey, proxyUrl.Scheme)
+	}
+
+	if username := conn[consts.ProxyUsernameKey]; username != "" {
+		proxyUrl.User = url.UserPassword(username, conn[consts.ProxyPasswordKey])
+	}
+
+	noProxy := parseNoProxy(conn[consts.NoProxyKey])
+
+	return func(request *http.Request) (*url.URL, error) {
+		if noProxy.matches(request.URL) {
+			return nil, nil
+		}
+		return proxyUrl, nil
+	}, nil
+}
+
+type noProxyList struct {
+	all      bool
+	networks []*net.IPNet
+	hosts    []string
+}
+
+// parseNoProxy parses a comma separated list of hosts that bypass the proxy. entries can be
+// '*', IPs, CIDRs, or domains which also match their subdomains, optionally with a port
+func parseNoProxy(value string) *noProxyList {
+	list := &noProxyList{}
+	for _, entry := range strings.Split(value, consts.ArrayDelimiter) {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			list.all = true
+			continue
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			list.networks = append(list.networks, network)
+			continue
+		}
+		list.hosts = append(list.hosts, strings.TrimPrefix(entry, "."))
+	}
+	return list
+}
+
+func (l *noProxyList) matches(requestUrl *url.URL) bool {
+	if l.all {
+		return true
+	}
+
+	host := strings.ToLower(requestUrl.Hostname())
+	port := requestUrl.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443", "ws": "80", "wss": "443"}[requestUrl.Scheme]
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		for _, network := range l.networks {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+	}
+
+	for _, entry := range l.hosts {
+		entryHost, entryPort := entry, ""
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			entryHost, entryPort = h, p
+		}
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+		if host == entryHost || strings.HasSuffix(host, "."+entryHost) {
+			return true
+		}
+	}
+	return false
+}
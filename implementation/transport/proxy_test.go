@@ -0,0 +1,81 @@
+package transport
+
+import (
+	"github.com/blinkops/blink-http/consts"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ProxyTestSuite struct {
+	suite.Suite
+}
+
+func TestProxyTestSuite(t *testing.T) {
+	suite.Run(t, new(ProxyTestSuite))
+}
+
+func (suite *ProxyTestSuite) TestGetProxy() {
+	proxy, err := getProxy(map[string]string{})
+	suite.Nil(err)
+	suite.Nil(proxy)
+
+	proxy, err = getProxy(map[string]string{
+		consts.ProxyUrlKey:      "http://proxy.corp:3128",
+		consts.ProxyUsernameKey: "user",
+		consts.ProxyPasswordKey: "p@ss",
+		consts.NoProxyKey:       "internal.corp, 10.0.0.0/8, localhost:8080",
+	})
+	suite.Nil(err)
+
+	for requestUrl, expectProxy := range map[string]bool{
+		"https://api.github.com/repos":  true,
+		"https://internal.corp/api":     false,
+		"https://svc.internal.corp/api": false,
+		"https://notinternal.corp/api":  true,
+		"http://10.1.2.3/api":           false,
+		"http://192.168.1.1/api":        true,
+		"http://localhost:8080/health":  false,
+		"http://localhost:9090/health":  true,
+	} {
+		u, err := url.Parse(requestUrl)
+		suite.Nil(err)
+		proxyUrl, err := proxy(&http.Request{URL: u})
+		suite.Nil(err)
+		if !expectProxy {
+			suite.Nil(proxyUrl, requestUrl)
+			continue
+		}
+		suite.Equal("proxy.corp:3128", proxyUrl.Host, requestUrl)
+		password, _ := proxyUrl.User.Password()
+		suite.Equal("user", proxyUrl.User.Username())
+		suite.Equal("p@ss", password)
+	}
+
+	proxy, err = getProxy(map[string]string{consts.ProxyUrlKey: "socks5://proxy.corp:1080", consts.NoProxyKey: "*"})
+	suite.Nil(err)
+	u, _ := url.Parse("https://api.github.com")
+	proxyUrl, err := proxy(&http.Request{URL: u})
+	suite.Nil(err)
+	suite.Nil(proxyUrl)
+
+	for _, badConnection := range []map[string]string{
+		{consts.ProxyUrlKey: "proxy.corp:3128"},
+		{consts.ProxyUrlKey: "ftp://proxy.corp:21"},
+	} {
+		_, err = getProxy(badConnection)
+		suite.NotNil(err)
+	}
+}
+
+func (suite *ProxyTestSuite) TestGetTokenTransportSettings() {
+	settings := GetTokenTransportSettings(map[string]string{
+		consts.ProxyUrlKey:      "http://proxy.corp:3128",
+		consts.TlsCaCertKey:     "ca",
+		consts.TlsServerNameKey: "internal.host",
+		"Token":                 "secret",
+	})
+	suite.Equal(map[string]string{consts.ProxyUrlKey: "http://proxy.corp:3128", consts.TlsCaCertKey: "ca"}, settings)
+}
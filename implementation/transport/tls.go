@@ -0,0 +1,108 @@
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/blinkops/blink-http/consts"
+	"golang.org/x/crypto/pkcs12"
+	"strings"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// getTlsConfig returns nil when the connection has no TLS settings, so the default TLS config is used
+func getTlsConfig(conn map[string]string) (*tls.Config, error) {
+	caCert := strings.TrimSpace(conn[consts.TlsCaCertKey])
+	clientCert := strings.TrimSpace(conn[consts.TlsClientCertKey])
+	clientKey := strings.TrimSpace(conn[consts.TlsClientKeyKey])
+	pkcs12Data := strings.TrimSpace(conn[consts.TlsPkcs12Key])
+	serverName := strings.TrimSpace(conn[consts.TlsServerNameKey])
+	minVersion := strings.TrimSpace(conn[consts.TlsMinVersionKey])
+
+	if caCert == "" && clientCert == "" && clientKey == "" && pkcs12Data == "" && serverName == "" && minVersion == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{ServerName: serverName}
+
+	if caCert != "" {
+		// the CA bundle is trusted in addition to the system's CAs
+		certPool, err := x509.SystemCertPool()
+		if err != nil || certPool == nil {
+			certPool = x509.NewCertPool()
+		}
+		if !certPool.AppendCertsFromPEM([]byte(caCert)) {
+			return nil, fmt.Errorf("%s does not contain any valid PEM certificate", consts.TlsCaCertKey)
+		}
+		tlsConfig.RootCAs = certPool
+	}
+
+	if pkcs12Data != "" && (clientCert != "" || clientKey != "") {
+		return nil, fmt.Errorf("provide either %s or %s and %s, not both", consts.TlsPkcs12Key, consts.TlsClientCertKey, consts.TlsClientKeyKey)
+	}
+
+	if pkcs12Data != "" {
+		certificate, err := parsePkcs12(pkcs12Data, conn[consts.TlsPkcs12PasswordKey])
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	if clientCert != "" || clientKey != "" {
+		if clientCert == "" || clientKey == "" {
+			return nil, fmt.Errorf("a client certificate requires both %s and %s", consts.TlsClientCertKey, consts.TlsClientKeyKey)
+		}
+		certificate, err := tls.X509KeyPair([]byte(clientCert), []byte(clientKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate or key, error: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	if minVersion != "" {
+		version, ok := tlsVersions[minVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid %s: %s, must be one of 1.0, 1.1, 1.2, 1.3", consts.TlsMinVersionKey, minVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	return tlsConfig, nil
+}
+
+// parsePkcs12 decodes a base64 encoded PKCS#12 archive (.p12/.pfx) into a client certificate
+func parsePkcs12(data string, password string) (tls.Certificate, error) {
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("%s must be base64 encoded, error: %v", consts.TlsPkcs12Key, err)
+	}
+
+	blocks, err := pkcs12.ToPEM(decoded, password)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to decode %s, error: %v", consts.TlsPkcs12Key, err)
+	}
+
+	var certPEM, keyPEM []byte
+	for _, block := range blocks {
+		if block.Type == "CERTIFICATE" {
+			certPEM = append(certPEM, pem.EncodeToMemory(block)...)
+		} else {
+			keyPEM = append(keyPEM, pem.EncodeToMemory(block)...)
+		}
+	}
+	if certPEM == nil || keyPEM == nil {
+		return tls.Certificate{}, errors.New(consts.TlsPkcs12Key + " must contain both a certificate and a private key")
+	}
+
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
@@ -0,0 +1,117 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/blinkops/blink-http/consts"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type TlsTestSuite struct {
+	suite.Suite
+	certPEM string
+	keyPEM  string
+}
+
+func TestTlsTestSuite(t *testing.T) {
+	suite.Run(t, new(TlsTestSuite))
+}
+
+func (suite *TlsTestSuite) SetupSuite() {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	suite.Require().Nil(err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "blink-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	suite.Require().Nil(err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	suite.Require().Nil(err)
+
+	suite.certPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	suite.keyPEM = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+}
+
+func (suite *TlsTestSuite) TestGetTlsConfig() {
+	tlsConfig, err := getTlsConfig(map[string]string{consts.RequestUrlKey: "https://host.com"})
+	suite.Nil(err)
+	suite.Nil(tlsConfig)
+
+	tlsConfig, err = getTlsConfig(map[string]string{
+		consts.TlsCaCertKey:     suite.certPEM,
+		consts.TlsClientCertKey: suite.certPEM,
+		consts.TlsClientKeyKey:  suite.keyPEM,
+		consts.TlsServerNameKey: "internal.host",
+		consts.TlsMinVersionKey: "1.2",
+	})
+	suite.Nil(err)
+	suite.NotNil(tlsConfig.RootCAs)
+	suite.Len(tlsConfig.Certificates, 1)
+	suite.Equal("internal.host", tlsConfig.ServerName)
+	suite.Equal(uint16(tls.VersionTLS12), tlsConfig.MinVersion)
+
+	for _, badConnection := range []map[string]string{
+		{consts.TlsCaCertKey: "not a certificate"},
+		{consts.TlsClientCertKey: suite.certPEM},
+		{consts.TlsClientKeyKey: suite.keyPEM},
+		{consts.TlsClientCertKey: suite.certPEM, consts.TlsClientKeyKey: "bad key"},
+		{consts.TlsPkcs12Key: "not base64!"},
+		{consts.TlsPkcs12Key: "aGVsbG8=", consts.TlsClientCertKey: suite.certPEM, consts.TlsClientKeyKey: suite.keyPEM},
+		{consts.TlsMinVersionKey: "1.4"},
+	} {
+		_, err = getTlsConfig(badConnection)
+		suite.NotNil(err)
+	}
+}
+
+func (suite *TlsTestSuite) TestIsTransportKey() {
+	suite.True(IsTransportKey(consts.TlsClientKeyKey))
+	suite.False(IsTransportKey(consts.RequestUrlKey))
+	suite.False(IsTransportKey("Token"))
+
+	suite.True(HasTransportSettings(map[string]string{consts.TlsServerNameKey: "host"}))
+	suite.False(HasTransportSettings(map[string]string{consts.TlsServerNameKey: "", "Token": "abc"}))
+}
+
+func (suite *TlsTestSuite) TestTokenClientTrustsConnectionCa() {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"access_token": "token"}`))
+	}))
+	defer server.Close()
+
+	conn := map[string]string{
+		consts.TlsCaCertKey:     string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})),
+		consts.TlsServerNameKey: "service.internal",
+	}
+	client, err := NewClient(GetTokenTransportSettings(conn), 5*time.Second)
+	suite.Require().Nil(err)
+	response, err := client.Get(server.URL)
+	suite.Require().Nil(err)
+	_ = response.Body.Close()
+	suite.Equal(http.StatusOK, response.StatusCode)
+
+	// without the connection's CA the server's certificate isn't trusted
+	client, err = NewClient(map[string]string{}, 5*time.Second)
+	suite.Require().Nil(err)
+	_, err = client.Get(server.URL)
+	suite.NotNil(err)
+}
@@ -0,0 +1,90 @@
+package transport
+
+import (
+	"fmt"
+	"github.com/blinkops/blink-http/consts"
+	"net/http"
+	"time"
+)
+
+var transportKeys = map[string]bool{
+	consts.TlsCaCertKey:         true,
+	consts.TlsClientCertKey:     true,
+	consts.TlsClientKeyKey:      true,
+	consts.TlsPkcs12Key:         true,
+	consts.TlsPkcs12PasswordKey: true,
+	consts.TlsServerNameKey:     true,
+	consts.TlsMinVersionKey:     true,
+	consts.ProxyUrlKey:          true,
+	consts.ProxyUsernameKey:     true,
+	consts.ProxyPasswordKey:     true,
+	consts.NoProxyKey:           true,
+}
+
+// IsTransportKey reports whether a connection attribute configures the transport
+// rather than the authentication, such attributes must never be sent as headers.
+func IsTransportKey(key string) bool {
+	return transportKeys[key]
+}
+
+// HasTransportSettings reports whether the connection customizes the transport
+func HasTransportSettings(conn map[string]string) bool {
+	for key, value := range conn {
+		if transportKeys[key] && value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// GetTokenTransportSettings returns the transport settings of the connection for requests to other hosts than the
+// connection's service, like oauth token requests. they go through the same proxy and trust the same CA bundle and
+// present the same client certificate, so identity providers behind a private CA or mutual TLS are reachable.
+// the server name override is left out, it belongs to the service's host
+func GetTokenTransportSettings(conn map[string]string) map[string]string {
+	settings := map[string]string{}
+	for key, value := range conn {
+		if transportKeys[key] && key != consts.TlsServerNameKey {
+			settings[key] = value
+		}
+	}
+	return settings
+}
+
+// NewTransport returns a new transport with the connection's TLS and proxy settings applied,
+// use GetTransport to reuse connections across requests
+func NewTransport(conn map[string]string) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	applyPoolSettings(transport, poolSettings)
+
+	tlsConfig, err := getTlsConfig(conn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connection TLS settings, error: %v", err)
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	proxy, err := getProxy(conn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid connection proxy settings, error: %v", err)
+	}
+	if proxy != nil {
+		transport.Proxy = proxy
+	}
+
+	return transport, nil
+}
+
+// NewClient returns a client that uses the shared transport for the connection's settings
+func NewClient(conn map[string]string, timeout time.Duration) (*http.Client, error) {
+	transport, err := GetTransport(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}, nil
+}
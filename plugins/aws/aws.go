@@ -0,0 +1,128 @@
+package aws
+
+import (
+	"fmt"
+	"github.com/blinkops/blink-http/plugins/tokens"
+	blink_conn "github.com/blinkops/blink-sdk/plugin/connections"
+	"net/http"
+	"strings"
+)
+
+const (
+	accessKeyIdKey     = "ACCESS_KEY_ID"
+	secretAccessKeyKey = "SECRET_ACCESS_KEY"
+	sessionTokenKey    = "SESSION_TOKEN"
+	regionKey          = "REGION"
+	serviceKey         = "SERVICE"
+	roleArnKey         = "ROLE_ARN"
+	externalIdKey      = "EXTERNAL_ID"
+
+	defaultRegion = "us-east-1"
+)
+
+type AwsPlugin struct{}
+
+// HandleAuth signs the request with SigV4, the region and service are taken from the connection
+// or from the request host ({service}.{region}.amazonaws.com)
+func (p AwsPlugin) HandleAuth(req *http.Request, conn map[string]string) error {
+	creds, err := getCredentials(conn)
+	if err != nil {
+		return err
+	}
+
+	region, service := getRegionAndService(conn, req.URL.Hostname())
+	if service == "" {
+		return fmt.Errorf("could not detect the aws service of %s, please set %s in the connection", req.URL.Host, serviceKey)
+	}
+	return signRequest(req, creds, region, service)
+}
+
+func (p AwsPlugin) TestConnection(connection *blink_conn.ConnectionInstance) (bool, []byte) {
+	creds, err := getCredentials(connection.Data)
+	if err != nil {
+		return false, []byte("Test connection failed. " + err.Error())
+	}
+
+	if _, err = getCallerIdentity(connection.Data, creds); err != nil {
+		return false, []byte("Test connection failed. " + err.Error())
+	}
+	return true, nil
+}
+
+func (p AwsPlugin) GetDefaultRequestUrl() string {
+	return "https://amazonaws.com"
+}
+
+// GetDefaultRequestUrls allows the endpoints of the China regions as well, which are under amazonaws.com.cn
+func (p AwsPlugin) GetDefaultRequestUrls() []string {
+	return []string{"https://amazonaws.com", "https://amazonaws.com.cn"}
+}
+
+// getCredentials returns the connection's keys, or the temporary credentials of the role when ROLE_ARN is set
+func getCredentials(conn map[string]string) (credentials, error) {
+	creds := credentials{
+		accessKeyId:     conn[accessKeyIdKey],
+		secretAccessKey: conn[secretAccessKeyKey],
+		sessionToken:    conn[sessionTokenKey],
+	}
+	if creds.accessKeyId == "" || creds.secretAccessKey == "" {
+		return credentials{}, fmt.Errorf("aws connection requires %s and %s", accessKeyIdKey, secretAccessKeyKey)
+	}
+
+	if conn[roleArnKey] == "" {
+		return creds, nil
+	}
+
+	token, err := tokens.GetToken("aws", conn, func() (*tokens.Token, error) {
+		return assumeRole(conn, creds)
+	})
+	if err != nil {
+		return credentials{}, err
+	}
+	return credentials{
+		accessKeyId:     token.Extra[accessKeyIdKey],
+		secretAccessKey: token.Extra[secretAccessKeyKey],
+		sessionToken:    token.AccessToken,
+	}, nil
+}
+
+func getRegionAndService(conn map[string]string, host string) (string, string) {
+	region, service := conn[regionKey], conn[serviceKey]
+
+	hostRegion, hostService := parseHost(host)
+	if region == "" {
+		region = hostRegion
+	}
+	if region == "" {
+		region = defaultRegion
+	}
+	if service == "" {
+		service = hostService
+	}
+	return region, service
+}
+
+// parseHost detects the region and service of endpoints like sts.us-east-1.amazonaws.com,
+// bucket.s3.eu-west-1.amazonaws.com, abc123.execute-api.us-east-1.amazonaws.com and iam.amazonaws.com
+func parseHost(host string) (string, string) {
+	host = strings.ToLower(host)
+	trimmed := strings.TrimSuffix(strings.TrimSuffix(host, ".amazonaws.com.cn"), ".amazonaws.com")
+	if trimmed == host || trimmed == "" {
+		return "", ""
+	}
+
+	parts := strings.Split(trimmed, ".")
+	last := parts[len(parts)-1]
+	if len(parts) > 1 && isRegion(last) {
+		return last, parts[len(parts)-2]
+	}
+	return "", last
+}
+
+func isRegion(value string) bool {
+	return strings.Count(value, "-") >= 2 && strings.ContainsAny(value[len(value)-1:], "0123456789")
+}
+
+func GetNewAwsPlugin() AwsPlugin {
+	return AwsPlugin{}
+}
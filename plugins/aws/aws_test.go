@@ -0,0 +1,137 @@
+package aws
+
+import (
+	"github.com/blinkops/blink-http/consts"
+	"github.com/blinkops/blink-http/plugins/tokens"
+	blink_conn "github.com/blinkops/blink-sdk/plugin/connections"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type AwsTestSuite struct {
+	suite.Suite
+}
+
+func TestAwsTestSuite(t *testing.T) {
+	suite.Run(t, new(AwsTestSuite))
+}
+
+// SetupTest empties the shared token cache, so the roles assumed by previous tests aren't reused
+func (suite *AwsTestSuite) SetupTest() {
+	tokens.ClearTokens()
+}
+
+// TestSignRequest uses the get-vanilla cases of the AWS SigV4 test suite
+func (suite *AwsTestSuite) TestSignRequest() {
+	defaultNow := now
+	defer func() { now = defaultNow }()
+	now = func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) }
+
+	creds := credentials{accessKeyId: "AKIDEXAMPLE", secretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+
+	for requestUrl, signature := range map[string]string{
+		"https://example.amazonaws.com/":                             "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		"https://example.amazonaws.com/?Param2=value2&Param1=value1": "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+	} {
+		request, err := http.NewRequest(http.MethodGet, requestUrl, nil)
+		suite.Require().Nil(err)
+		suite.Nil(signRequest(request, creds, "us-east-1", "service"))
+		suite.Equal("AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature="+signature, request.Header.Get("Authorization"))
+		suite.Equal("20150830T123600Z", request.Header.Get("X-Amz-Date"))
+	}
+}
+
+func (suite *AwsTestSuite) TestGetCanonicalQuery() {
+	requestUrl, err := url.Parse("https://example.amazonaws.com/?b=2&aZ=z&a%C3%A9=%C3%A9&b=%C3%A9&b=Z&a%20b=1")
+	suite.Require().Nil(err)
+
+	// the parameters are sorted after encoding, so the percent-encoded characters come before the letters
+	suite.Equal("a%20b=1&a%C3%A9=%C3%A9&aZ=z&b=%C3%A9&b=2&b=Z", getCanonicalQuery(requestUrl))
+}
+
+func (suite *AwsTestSuite) TestParseHost() {
+	for host, expected := range map[string][2]string{
+		"sts.us-east-1.amazonaws.com":                 {"us-east-1", "sts"},
+		"bucket.s3.eu-west-1.amazonaws.com":           {"eu-west-1", "s3"},
+		"abc123.execute-api.ap-south-1.amazonaws.com": {"ap-south-1", "execute-api"},
+		"iam.amazonaws.com":                           {"", "iam"},
+		"ec2.cn-north-1.amazonaws.com.cn":             {"cn-north-1", "ec2"},
+		"example.com":                                 {"", ""},
+	} {
+		region, service := parseHost(host)
+		suite.Equal(expected[0], region, host)
+		suite.Equal(expected[1], service, host)
+	}
+
+	region, service := getRegionAndService(map[string]string{serviceKey: "execute-api"}, "api.example.com")
+	suite.Equal(defaultRegion, region)
+	suite.Equal("execute-api", service)
+}
+
+func (suite *AwsTestSuite) TestAssumeRole() {
+	actions := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		suite.Nil(r.ParseForm())
+		actions = append(actions, r.PostForm.Get("Action"))
+		authorization := r.Header.Get("Authorization")
+
+		switch r.PostForm.Get("Action") {
+		case "AssumeRole":
+			suite.Contains(authorization, "Credential=AKIDBASE/")
+			suite.Equal("arn:aws:iam::123456789012:role/blink", r.PostForm.Get("RoleArn"))
+			suite.Equal("external", r.PostForm.Get("ExternalId"))
+			_, _ = w.Write([]byte(`<AssumeRoleResponse><AssumeRoleResult><Credentials>
+				<AccessKeyId>ASIAROLE</AccessKeyId><SecretAccessKey>secret</SecretAccessKey>
+				<SessionToken>session</SessionToken><Expiration>` + time.Now().Add(time.Hour).UTC().Format(time.RFC3339) + `</Expiration>
+				</Credentials></AssumeRoleResult></AssumeRoleResponse>`))
+		case "GetCallerIdentity":
+			if !strings.Contains(authorization, "Credential=ASIAROLE/") || r.Header.Get("X-Amz-Security-Token") != "session" {
+				w.WriteHeader(http.StatusForbidden)
+				_, _ = w.Write([]byte(`<ErrorResponse><Error><Code>InvalidClientTokenId</Code><Message>invalid token</Message></Error></ErrorResponse>`))
+				return
+			}
+			_, _ = w.Write([]byte(`<GetCallerIdentityResponse><GetCallerIdentityResult>
+				<Arn>arn:aws:sts::123456789012:assumed-role/blink/blink-http</Arn><Account>123456789012</Account>
+				</GetCallerIdentityResult></GetCallerIdentityResponse>`))
+		}
+	}))
+	defer server.Close()
+
+	defaultEndpoint := stsEndpoint
+	defer func() { stsEndpoint = defaultEndpoint }()
+	stsEndpoint = func(region string) string { return server.URL + "/" }
+
+	conn := map[string]string{
+		accessKeyIdKey:     "AKIDBASE",
+		secretAccessKeyKey: "base-secret",
+		roleArnKey:         "arn:aws:iam::123456789012:role/blink",
+		externalIdKey:      "external",
+	}
+
+	plugin := GetNewAwsPlugin()
+	valid, response := plugin.TestConnection(&blink_conn.ConnectionInstance{Data: conn})
+	suite.True(valid, string(response))
+
+	request, err := http.NewRequest(http.MethodGet, "https://ec2.eu-west-1.amazonaws.com/?Action=DescribeInstances", nil)
+	suite.Require().Nil(err)
+	suite.Nil(plugin.HandleAuth(request, conn))
+	suite.Contains(request.Header.Get("Authorization"), "Credential=ASIAROLE/")
+	suite.Contains(request.Header.Get("Authorization"), "/eu-west-1/ec2/aws4_request")
+	suite.Equal([]string{"AssumeRole", "GetCallerIdentity"}, actions, "the assumed role should be cached")
+
+	valid, response = plugin.TestConnection(&blink_conn.ConnectionInstance{Data: map[string]string{
+		accessKeyIdKey:     "AKIDOTHER",
+		secretAccessKeyKey: "other-secret",
+	}})
+	suite.False(valid)
+	suite.Contains(string(response), "InvalidClientTokenId")
+
+	valid, _ = plugin.TestConnection(&blink_conn.ConnectionInstance{Data: map[string]string{consts.RequestUrlKey: server.URL}})
+	suite.False(valid)
+}
@@ -0,0 +1,189 @@
+package aws
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	signingAlgorithm = "AWS4-HMAC-SHA256"
+	amzDateFormat    = "20060102T150405Z"
+	unsignedPayload  = "UNSIGNED-PAYLOAD"
+)
+
+// now is replaced in tests to sign with a fixed date
+var now = time.Now
+
+type credentials struct {
+	accessKeyId     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+// signRequest signs the request with AWS Signature Version 4, the body is hashed so it must be set before signing
+// https://docs.aws.amazon.com/general/latest/gr/sigv4_signing.html
+func signRequest(req *http.Request, creds credentials, region string, service string) error {
+	signingTime := now().UTC()
+	amzDate := signingTime.Format(amzDateFormat)
+
+	payloadHash, err := getPayloadHash(req)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Del("Authorization")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+	if service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	signedHeaders, canonicalHeaders := getCanonicalHeaders(req.Header, host)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		getCanonicalPath(req.URL, service),
+		getCanonicalQuery(req.URL),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{signingTime.Format("20060102"), region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{signingAlgorithm, amzDate, scope, hashHex([]byte(canonicalRequest))}, "\n")
+
+	signingKey := hmacSha256([]byte("AWS4"+creds.secretAccessKey), signingTime.Format("20060102"))
+	for _, part := range []string{region, service, "aws4_request"} {
+		signingKey = hmacSha256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSha256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", signingAlgorithm+" Credential="+creds.accessKeyId+"/"+scope+", SignedHeaders="+signedHeaders+", Signature="+signature)
+	return nil
+}
+
+func getPayloadHash(req *http.Request) (string, error) {
+	if value := req.Header.Get("X-Amz-Content-Sha256"); value == unsignedPayload {
+		return value, nil
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return hashHex(nil), nil
+	}
+	if req.GetBody == nil {
+		return unsignedPayload, nil
+	}
+
+	reader, err := req.GetBody()
+	if err != nil {
+		return "", err
+	}
+	body, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	return hashHex(body), nil
+}
+
+// getCanonicalHeaders signs the host, the content type and all the x-amz-* headers
+func getCanonicalHeaders(header http.Header, host string) (string, string) {
+	values := map[string]string{"host": host}
+	for name, headerValues := range header {
+		lowerName := strings.ToLower(name)
+		if lowerName != "content-type" && !strings.HasPrefix(lowerName, "x-amz-") {
+			continue
+		}
+		trimmed := make([]string, len(headerValues))
+		for i, value := range headerValues {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		values[lowerName] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	canonical := strings.Builder{}
+	for _, name := range names {
+		canonical.WriteString(name + ":" + values[name] + "\n")
+	}
+	return strings.Join(names, ";"), canonical.String()
+}
+
+// getCanonicalPath encodes the path twice for every service except s3
+func getCanonicalPath(requestUrl *url.URL, service string) string {
+	path := requestUrl.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	if service == "s3" {
+		return path
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// getCanonicalQuery sorts the parameters by their encoded names and values, as the encoding changes their order
+func getCanonicalQuery(requestUrl *url.URL) string {
+	var params [][2]string
+	for key, values := range requestUrl.Query() {
+		for _, value := range values {
+			params = append(params, [2]string{uriEncode(key), uriEncode(value)})
+		}
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i][0] != params[j][0] {
+			return params[i][0] < params[j][0]
+		}
+		return params[i][1] < params[j][1]
+	})
+
+	pairs := make([]string, 0, len(params))
+	for _, param := range params {
+		pairs = append(pairs, param[0]+"="+param[1])
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode encodes everything except the unreserved characters of RFC 3986
+func uriEncode(value string) string {
+	encoded := strings.Builder{}
+	for _, b := range []byte(value) {
+		if b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z' || b >= '0' && b <= '9' || b == '-' || b == '_' || b == '.' || b == '~' {
+			encoded.WriteByte(b)
+			continue
+		}
+		encoded.WriteString("%" + strings.ToUpper(hex.EncodeToString([]byte{b})))
+	}
+	return encoded.String()
+}
+
+func hashHex(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
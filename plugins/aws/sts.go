@@ -0,0 +1,135 @@
+package aws
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/blinkops/blink-http/consts"
+	"github.com/blinkops/blink-http/implementation/transport"
+	"github.com/blinkops/blink-http/plugins/tokens"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	stsVersion      = "2011-06-15"
+	roleSessionName = "blink-http"
+)
+
+// stsEndpoint is replaced in tests
+var stsEndpoint = func(region string) string {
+	if strings.HasPrefix(region, "cn-") {
+		return fmt.Sprintf("https://sts.%s.amazonaws.com.cn/", region)
+	}
+	return fmt.Sprintf("https://sts.%s.amazonaws.com/", region)
+}
+
+type stsError struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+type callerIdentity struct {
+	Account string `xml:"GetCallerIdentityResult>Account"`
+	Arn     string `xml:"GetCallerIdentityResult>Arn"`
+	UserId  string `xml:"GetCallerIdentityResult>UserId"`
+}
+
+// assumeRole returns the temporary credentials of the connection's role as a token, so they are cached until they expire
+func assumeRole(conn map[string]string, creds credentials) (*tokens.Token, error) {
+	params := url.Values{
+		"RoleArn":         {conn[roleArnKey]},
+		"RoleSessionName": {roleSessionName},
+	}
+	if externalId := conn[externalIdKey]; externalId != "" {
+		params.Set("ExternalId", externalId)
+	}
+
+	var response struct {
+		AccessKeyId     string `xml:"AssumeRoleResult>Credentials>AccessKeyId"`
+		SecretAccessKey string `xml:"AssumeRoleResult>Credentials>SecretAccessKey"`
+		SessionToken    string `xml:"AssumeRoleResult>Credentials>SessionToken"`
+		Expiration      string `xml:"AssumeRoleResult>Credentials>Expiration"`
+	}
+	if err := callSts(conn, creds, "AssumeRole", params, &response); err != nil {
+		return nil, fmt.Errorf("failed to assume role %s, error: %v", conn[roleArnKey], err)
+	}
+	if response.AccessKeyId == "" || response.SessionToken == "" {
+		return nil, fmt.Errorf("failed to assume role %s, the response does not contain credentials", conn[roleArnKey])
+	}
+
+	token := &tokens.Token{
+		AccessToken: response.SessionToken,
+		Extra: map[string]string{
+			accessKeyIdKey:     response.AccessKeyId,
+			secretAccessKeyKey: response.SecretAccessKey,
+		},
+	}
+	if expiration, err := time.Parse(time.RFC3339, response.Expiration); err == nil {
+		token.Expiry = expiration
+	}
+	return token, nil
+}
+
+func getCallerIdentity(conn map[string]string, creds credentials) (*callerIdentity, error) {
+	identity := &callerIdentity{}
+	if err := callSts(conn, creds, "GetCallerIdentity", url.Values{}, identity); err != nil {
+		return nil, err
+	}
+	if identity.Arn == "" {
+		return nil, errors.New("the GetCallerIdentity response does not contain the caller identity")
+	}
+	return identity, nil
+}
+
+func callSts(conn map[string]string, creds credentials, action string, params url.Values, response interface{}) error {
+	region := conn[regionKey]
+	if region == "" {
+		region = defaultRegion
+	}
+
+	params.Set("Action", action)
+	params.Set("Version", stsVersion)
+
+	request, err := http.NewRequest(http.MethodPost, stsEndpoint(region), strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	if err = signRequest(request, creds, region, "sts"); err != nil {
+		return err
+	}
+
+	client, err := transport.NewClient(transport.GetTokenTransportSettings(conn), time.Second*time.Duration(consts.DefaultTimeout))
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusBadRequest {
+		errorResponse := stsError{}
+		if xml.Unmarshal(body, &errorResponse) == nil && errorResponse.Code != "" {
+			return fmt.Errorf("%s: %s (status %d)", errorResponse.Code, errorResponse.Message, res.StatusCode)
+		}
+		return fmt.Errorf("sts returned status %d: %s", res.StatusCode, string(body))
+	}
+
+	if err = xml.Unmarshal(body, response); err != nil {
+		return fmt.Errorf("failed to parse the %s response, error: %v", action, err)
+	}
+	return nil
+}
@@ -1,13 +1,16 @@
 package azure
 
 import (
-	"encoding/json"
 	"fmt"
+	"github.com/blinkops/blink-http/consts"
+	"github.com/blinkops/blink-http/implementation/transport"
+	"github.com/blinkops/blink-http/plugins/tokens"
 	blink_conn "github.com/blinkops/blink-sdk/plugin/connections"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 )
 
 type AzurePlugin struct{}
@@ -19,6 +22,17 @@ func (p AzurePlugin) HandleAuth(req *http.Request, conn map[string]string) error
 		return nil
 	}
 
+	token, err := tokens.GetToken("azure", conn, func() (*tokens.Token, error) {
+		return getAccessToken(conn)
+	})
+	if err != nil {
+		return err
+	}
+	req.Header.Set("AUTHORIZATION", consts.BearerAuthPrefix+token.AccessToken)
+	return nil
+}
+
+func getAccessToken(conn map[string]string) (*tokens.Token, error) {
 	queryParams := url.Values{
 		"grant_type":    {"client_credentials"},
 		"client_id":     {conn["app_id"]},
@@ -26,38 +40,31 @@ func (p AzurePlugin) HandleAuth(req *http.Request, conn map[string]string) error
 		"resource":      {"https://management.core.windows.net/"},
 	}
 
-	client := &http.Client{}
-
-	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/token", conn["tenant_id"]), strings.NewReader(queryParams.Encode()))
+	client, err := transport.NewClient(transport.GetTokenTransportSettings(conn), time.Second*time.Duration(consts.DefaultTimeout))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-
-	res, err := client.Do(req)
+	tokenRequest, err := http.NewRequest(http.MethodPost, fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/token", conn["tenant_id"]), strings.NewReader(queryParams.Encode()))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	defer func() { _ = res.Body.Close() }()
+	tokenRequest.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
-	body, err := ioutil.ReadAll(res.Body)
+	res, err := client.Do(tokenRequest)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var responseBody struct {
-		AccessToken string `json:"access_token"`
-	}
+	defer func() { _ = res.Body.Close() }()
 
-	err = json.Unmarshal(body, &responseBody)
+	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	req.Header.Set("AUTHORIZATION", "Bearer "+responseBody.AccessToken)
-	return nil
 
+	return tokens.ParseTokenResponse(res.StatusCode, body)
 }
 
 func (p AzurePlugin) TestConnection(connection *blink_conn.ConnectionInstance) (bool, []byte) {
@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"github.com/blinkops/blink-http/consts"
+	"github.com/blinkops/blink-http/implementation/transport"
 	"github.com/blinkops/blink-http/plugins/types"
 	blink_conn "github.com/blinkops/blink-sdk/plugin/connections"
 	"net/http"
@@ -19,8 +20,12 @@ type (
 
 func HandleGenericConnection(connection map[string]string, request *http.Request, prefixes HeaderValuePrefixes, headerAlias HeaderAlias) error {
 	headers := make(map[string]string)
-	delete(connection, consts.RequestUrlKey)
 	for header, headerValue := range connection {
+		// the request url and the transport settings are part of the connection but aren't headers.
+		// the connection is not modified since it's reused when a request is retried
+		if header == consts.RequestUrlKey || transport.IsTransportKey(header) {
+			continue
+		}
 		header = strings.ToUpper(header)
 		// if the header is in our alias map replace it with the value in the map
 		// TOKEN -> AUTHORIZATION
@@ -75,8 +80,9 @@ func SendTestConnectionRequest(url string, method string, data []byte, conn *bli
 		return nil, err
 	}
 
-	client := &http.Client{
-		Timeout: time.Second * time.Duration(consts.DefaultTimeout),
+	client, err := transport.NewClient(conn.Data, time.Second*time.Duration(consts.DefaultTimeout))
+	if err != nil {
+		return nil, err
 	}
 	return client.Do(req)
 }
@@ -0,0 +1,238 @@
+package digest
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strings"
+	"sync"
+)
+
+const (
+	qopAuth    = "auth"
+	qopAuthInt = "auth-int"
+)
+
+// algorithms are ordered by preference, when the server offers several challenges the strongest one is used
+var algorithms = []string{"SHA-256", "SHA-256-sess", "MD5", "MD5-sess"}
+
+var hashFunctions = map[string]func() hash.Hash{
+	"MD5":     md5.New,
+	"SHA-256": sha256.New,
+}
+
+// newCnonce is replaced in tests to get a deterministic response
+var newCnonce = func() string {
+	nonce := make([]byte, 16)
+	_, _ = rand.Read(nonce)
+	return hex.EncodeToString(nonce)
+}
+
+// challenge is a digest challenge (RFC 7616) received in a WWW-Authenticate header
+type challenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	userhash  bool
+	count     int
+}
+
+// parseChallenge returns the strongest supported digest challenge, or nil if there is none
+func parseChallenge(headers []string) (*challenge, error) {
+	var best *challenge
+	bestRank := len(algorithms)
+
+	for _, header := range headers {
+		header = strings.TrimSpace(header)
+		if len(header) < 7 || !strings.EqualFold(header[:7], "Digest ") {
+			continue
+		}
+		params := parseParams(header[7:])
+
+		algorithm := params["algorithm"]
+		if algorithm == "" {
+			algorithm = "MD5"
+		}
+		rank := -1
+		for i, supported := range algorithms {
+			if strings.EqualFold(algorithm, supported) {
+				rank = i
+				algorithm = supported
+			}
+		}
+		if rank < 0 || rank >= bestRank {
+			continue
+		}
+
+		if params["nonce"] == "" {
+			return nil, fmt.Errorf("digest challenge is missing a nonce")
+		}
+
+		qop, err := selectQop(params["qop"])
+		if err != nil {
+			return nil, err
+		}
+
+		best = &challenge{
+			realm:     params["realm"],
+			nonce:     params["nonce"],
+			opaque:    params["opaque"],
+			algorithm: algorithm,
+			qop:       qop,
+			userhash:  strings.EqualFold(params["userhash"], "true"),
+		}
+		bestRank = rank
+	}
+
+	return best, nil
+}
+
+// selectQop prefers auth, which doesn't require hashing the body, over auth-int
+func selectQop(offered string) (string, error) {
+	if offered == "" {
+		return "", nil
+	}
+	authInt := false
+	for _, qop := range strings.Split(offered, ",") {
+		switch strings.ToLower(strings.TrimSpace(qop)) {
+		case qopAuth:
+			return qopAuth, nil
+		case qopAuthInt:
+			authInt = true
+		}
+	}
+	if authInt {
+		return qopAuthInt, nil
+	}
+	return "", fmt.Errorf("unsupported digest qop: %s", offered)
+}
+
+// parseParams parses comma separated name=value pairs, values may be quoted strings
+func parseParams(value string) map[string]string {
+	params := map[string]string{}
+	for {
+		value = strings.TrimLeft(value, " \t,")
+		equals := strings.IndexByte(value, '=')
+		if equals < 0 {
+			return params
+		}
+		name := strings.ToLower(strings.TrimSpace(value[:equals]))
+		value = strings.TrimLeft(value[equals+1:], " \t")
+
+		if strings.HasPrefix(value, `"`) {
+			builder := strings.Builder{}
+			i := 1
+			for ; i < len(value) && value[i] != '"'; i++ {
+				if value[i] == '\\' && i+1 < len(value) {
+					i++
+				}
+				builder.WriteByte(value[i])
+			}
+			params[name] = builder.String()
+			if i < len(value) {
+				i++
+			}
+			value = value[i:]
+			continue
+		}
+
+		end := strings.IndexByte(value, ',')
+		if end < 0 {
+			end = len(value)
+		}
+		params[name] = strings.TrimSpace(value[:end])
+		value = value[end:]
+	}
+}
+
+// authorization returns the Authorization header value for a request, body is only used with auth-int
+func (c *challenge) authorization(username string, password string, method string, uri string, body []byte) string {
+	algorithm := strings.TrimSuffix(c.algorithm, "-sess")
+	h := func(data string) string {
+		digest := hashFunctions[algorithm]()
+		digest.Write([]byte(data))
+		return hex.EncodeToString(digest.Sum(nil))
+	}
+
+	cnonce := newCnonce()
+	nc := fmt.Sprintf("%08x", c.count)
+
+	ha1 := h(username + ":" + c.realm + ":" + password)
+	if strings.HasSuffix(c.algorithm, "-sess") {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+
+	ha2 := h(method + ":" + uri)
+	if c.qop == qopAuthInt {
+		ha2 = h(method + ":" + uri + ":" + h(string(body)))
+	}
+
+	var response string
+	if c.qop == "" {
+		response = h(ha1 + ":" + c.nonce + ":" + ha2)
+	} else {
+		response = h(ha1 + ":" + c.nonce + ":" + nc + ":" + cnonce + ":" + c.qop + ":" + ha2)
+	}
+
+	if c.userhash {
+		username = h(username + ":" + c.realm)
+	}
+
+	params := []string{
+		fmt.Sprintf(`username="%s"`, quote(username)),
+		fmt.Sprintf(`realm="%s"`, quote(c.realm)),
+		fmt.Sprintf(`uri="%s"`, quote(uri)),
+		"algorithm=" + c.algorithm,
+		fmt.Sprintf(`nonce="%s"`, quote(c.nonce)),
+	}
+	if c.qop != "" {
+		params = append(params, "nc="+nc, fmt.Sprintf(`cnonce="%s"`, cnonce), "qop="+c.qop)
+	}
+	params = append(params, fmt.Sprintf(`response="%s"`, response))
+	if c.opaque != "" {
+		params = append(params, fmt.Sprintf(`opaque="%s"`, quote(c.opaque)))
+	}
+	if c.userhash {
+		params = append(params, "userhash=true")
+	}
+
+	return "Digest " + strings.Join(params, ", ")
+}
+
+func quote(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value)
+}
+
+// challengeCache keeps the last challenge of every host and user, so following requests
+// are authenticated up front and the nonce count keeps increasing
+type challengeCache struct {
+	lock       sync.Mutex
+	challenges map[string]*challenge
+}
+
+func newChallengeCache() *challengeCache {
+	return &challengeCache{challenges: map[string]*challenge{}}
+}
+
+func (c *challengeCache) set(key string, value *challenge) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.challenges[key] = value
+}
+
+// next returns a copy of the challenge with the nonce count of the next request
+func (c *challengeCache) next(key string) (challenge, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	current, ok := c.challenges[key]
+	if !ok {
+		return challenge{}, false
+	}
+	current.count++
+	return *current, true
+}
@@ -0,0 +1,124 @@
+package digest
+
+import (
+	"errors"
+	"fmt"
+	"github.com/blinkops/blink-http/consts"
+	"github.com/blinkops/blink-http/plugins/connections"
+	blink_conn "github.com/blinkops/blink-sdk/plugin/connections"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+type DigestPlugin struct {
+	challenges *challengeCache
+}
+
+// HandleAuth authenticates with the last challenge received from the host, the first
+// request is sent without credentials and answered with a challenge (see HandleChallenge)
+func (p DigestPlugin) HandleAuth(req *http.Request, conn map[string]string) error {
+	username, password, err := getCredentials(conn)
+	if err != nil {
+		return err
+	}
+
+	current, ok := p.challenges.next(getCacheKey(req.URL.Host, username))
+	if !ok {
+		return nil
+	}
+
+	var body []byte
+	if current.qop == qopAuthInt && req.GetBody != nil {
+		reader, err := req.GetBody()
+		if err != nil {
+			return err
+		}
+		if body, err = ioutil.ReadAll(reader); err != nil {
+			return err
+		}
+	}
+
+	req.Header.Set("Authorization", current.authorization(username, password, req.Method, req.URL.RequestURI(), body))
+	return nil
+}
+
+// HandleChallenge stores the digest challenge of a 401 response and reports whether the request should be resent
+func (p DigestPlugin) HandleChallenge(response *http.Response, conn map[string]string) (bool, error) {
+	if response.StatusCode != http.StatusUnauthorized || response.Request == nil {
+		return false, nil
+	}
+
+	username, _, err := getCredentials(conn)
+	if err != nil {
+		return false, err
+	}
+
+	received, err := parseChallenge(response.Header.Values("WWW-Authenticate"))
+	if err != nil {
+		return false, err
+	}
+	if received == nil {
+		return false, errors.New("the server did not respond with a supported digest challenge")
+	}
+
+	p.challenges.set(getCacheKey(response.Request.URL.Host, username), received)
+	return true, nil
+}
+
+func (p DigestPlugin) TestConnection(connection *blink_conn.ConnectionInstance) (bool, []byte) {
+	requestUrl, ok := connection.Data[consts.RequestUrlKey]
+	if !ok {
+		return false, []byte("Test connection failed, API Address wasn't provided")
+	}
+
+	res, err := connections.SendTestConnectionRequest(requestUrl, http.MethodGet, nil, connection, p.HandleAuth)
+	if err != nil {
+		return false, []byte("Test connection failed. " + err.Error())
+	}
+
+	if res.StatusCode == http.StatusUnauthorized {
+		resend, err := p.HandleChallenge(res, connection.Data)
+		_, _ = io.Copy(ioutil.Discard, res.Body)
+		_ = res.Body.Close()
+		if err != nil {
+			return false, []byte("Test connection failed. " + err.Error())
+		}
+		if resend {
+			if res, err = connections.SendTestConnectionRequest(requestUrl, http.MethodGet, nil, connection, p.HandleAuth); err != nil {
+				return false, []byte("Test connection failed. " + err.Error())
+			}
+		}
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusBadRequest {
+		return false, []byte(fmt.Sprintf("Test connection failed. Got status code %v", res.StatusCode))
+	}
+
+	return true, nil
+}
+
+func (p DigestPlugin) GetDefaultRequestUrl() string {
+	return ""
+}
+
+func getCredentials(conn map[string]string) (string, string, error) {
+	username, ok := conn[consts.BasicAuthUsername]
+	if !ok {
+		return "", "", errors.New("digest-auth connection does not contain a USERNAME attribute")
+	}
+	password, ok := conn[consts.BasicAuthPassword]
+	if !ok {
+		return "", "", errors.New("digest-auth connection does not contain a PASSWORD attribute")
+	}
+	return username, password, nil
+}
+
+func getCacheKey(host string, username string) string {
+	return host + "\x00" + username
+}
+
+func GetNewDigestPlugin() DigestPlugin {
+	return DigestPlugin{challenges: newChallengeCache()}
+}
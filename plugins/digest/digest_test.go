@@ -0,0 +1,104 @@
+package digest
+
+import (
+	"github.com/blinkops/blink-http/consts"
+	blink_conn "github.com/blinkops/blink-sdk/plugin/connections"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type DigestTestSuite struct {
+	suite.Suite
+}
+
+func TestDigestTestSuite(t *testing.T) {
+	suite.Run(t, new(DigestTestSuite))
+}
+
+// TestAuthorization uses the examples of RFC 7616 section 3.9.1
+func (suite *DigestTestSuite) TestAuthorization() {
+	defaultCnonce := newCnonce
+	defer func() { newCnonce = defaultCnonce }()
+	newCnonce = func() string { return "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ" }
+
+	for algorithm, expected := range map[string]string{
+		"MD5":     "8ca523f5e9506fed4657c9700eebdbec",
+		"SHA-256": "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1",
+	} {
+		received, err := parseChallenge([]string{
+			`Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=` + algorithm + `, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`,
+		})
+		suite.Require().Nil(err)
+		suite.Equal(qopAuth, received.qop)
+
+		received.count = 1
+		header := received.authorization("Mufasa", "Circle of Life", http.MethodGet, "/dir/index.html", nil)
+		suite.Contains(header, `response="`+expected+`"`, algorithm)
+		suite.Contains(header, "nc=00000001")
+		suite.Contains(header, `opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`)
+	}
+}
+
+func (suite *DigestTestSuite) TestParseChallenge() {
+	received, err := parseChallenge([]string{
+		`Basic realm="fallback"`,
+		`Digest realm="api", qop="auth", algorithm=MD5, nonce="abc"`,
+		`Digest realm="api", qop="auth-int", algorithm=SHA-256, nonce="def", userhash=true`,
+	})
+	suite.Nil(err)
+	suite.Equal("SHA-256", received.algorithm)
+	suite.Equal("def", received.nonce)
+	suite.Equal(qopAuthInt, received.qop)
+	suite.True(received.userhash)
+
+	received, err = parseChallenge([]string{`Digest realm="legacy", nonce="abc"`})
+	suite.Nil(err)
+	suite.Equal("MD5", received.algorithm)
+	suite.Equal("", received.qop)
+
+	received, err = parseChallenge([]string{`Bearer realm="api"`, `Digest realm="api", algorithm=SHA-512-256, nonce="abc"`})
+	suite.Nil(err)
+	suite.Nil(received)
+
+	_, err = parseChallenge([]string{`Digest realm="api", qop="other", nonce="abc"`})
+	suite.NotNil(err)
+}
+
+func (suite *DigestTestSuite) TestTestConnection() {
+	authorizations := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		if !strings.HasPrefix(authorization, "Digest ") {
+			w.Header().Set("WWW-Authenticate", `Digest realm="test", qop="auth", algorithm=SHA-256, nonce="n1"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		authorizations++
+		params := parseParams(authorization[len("Digest "):])
+		suite.Equal("admin", params["username"])
+		suite.Equal("n1", params["nonce"])
+		suite.Equal("/status", params["uri"])
+	}))
+	defer server.Close()
+
+	plugin := GetNewDigestPlugin()
+	connection := &blink_conn.ConnectionInstance{Data: map[string]string{
+		consts.RequestUrlKey:     server.URL + "/status",
+		consts.BasicAuthUsername: "admin",
+		consts.BasicAuthPassword: "secret",
+	}}
+
+	valid, response := plugin.TestConnection(connection)
+	suite.True(valid, string(response))
+	suite.Equal(1, authorizations)
+
+	// the challenge is reused, so the next request is authenticated up front with the next nonce count
+	request, err := http.NewRequest(http.MethodGet, server.URL+"/status", nil)
+	suite.Require().Nil(err)
+	suite.Nil(plugin.HandleAuth(request, connection.Data))
+	suite.Contains(request.Header.Get("Authorization"), "nc=00000002")
+}
@@ -3,6 +3,8 @@ package gcp
 import (
 	"encoding/json"
 	"github.com/blinkops/blink-http/consts"
+	"github.com/blinkops/blink-http/implementation/transport"
+	"github.com/blinkops/blink-http/plugins/tokens"
 	blink_conn "github.com/blinkops/blink-sdk/plugin/connections"
 	"github.com/golang-jwt/jwt"
 	"github.com/pkg/errors"
@@ -38,35 +40,44 @@ func GetNewGcpPlugin() GcpPlugin {
 }
 
 func handleServiceAccountAuth(connection map[string]string, request *http.Request) error {
+	token, err := tokens.GetToken("gcp", connection, func() (*tokens.Token, error) {
+		return getServiceAccountToken(connection)
+	})
+	if err != nil {
+		return err
+	}
+	request.Header.Set("AUTHORIZATION", consts.BearerAuthPrefix+token.AccessToken)
+
+	return nil
+}
+
+func getServiceAccountToken(connection map[string]string) (*tokens.Token, error) {
 	credentialsString, ok := connection["credentials"]
 	if !ok {
-		return errors.New("credentials are invalid - could not convert to string")
+		return nil, errors.New("credentials are invalid - could not convert to string")
 	}
 
 	signedToken, err := generateJWTToken(credentialsString)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	req, err := constructReq(signedToken)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	client := &http.Client{}
-	res, err := client.Do(req)
-	defer func() { _ = res.Body.Close() }()
+	client, err := transport.NewClient(transport.GetTokenTransportSettings(connection), time.Second*time.Duration(consts.DefaultTimeout))
 	if err != nil {
-		return errors.Errorf("could not execute the http request: %v", err)
+		return nil, err
 	}
-
-	accessToken, err := extractAccessToken(res)
+	res, err := client.Do(req)
 	if err != nil {
-		return err
+		return nil, errors.Errorf("could not execute the http request: %v", err)
 	}
-	request.Header.Set("AUTHORIZATION", consts.BearerAuthPrefix+accessToken)
+	defer func() { _ = res.Body.Close() }()
 
-	return nil
+	return extractAccessToken(res)
 }
 
 func generateJWTToken(credentials string) (string, error) {
@@ -123,18 +134,15 @@ func constructReq(signedToken string) (*http.Request, error) {
 	return req, nil
 }
 
-func extractAccessToken(res *http.Response) (string, error) {
+func extractAccessToken(res *http.Response) (*tokens.Token, error) {
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		return "", errors.Errorf("an error occurred reading the response body: %v", err)
+		return nil, errors.Errorf("an error occurred reading the response body: %v", err)
 	}
 
-	var responseBody struct {
-		AccessToken string `json:"access_token"`
-	}
-	err = json.Unmarshal(body, &responseBody)
+	token, err := tokens.ParseTokenResponse(res.StatusCode, body)
 	if err != nil {
-		return "", errors.Errorf("could not get the access token from the response: %v", err)
+		return nil, errors.Errorf("could not get the access token from the response: %v", err)
 	}
-	return responseBody.AccessToken, nil
-}
\ No newline at end of file
+	return token, nil
+}
@@ -3,6 +3,7 @@ package github
 import (
 	"github.com/blinkops/blink-http/consts"
 	"github.com/blinkops/blink-http/plugins/connections"
+	"github.com/blinkops/blink-http/plugins/types"
 	blink_conn "github.com/blinkops/blink-sdk/plugin/connections"
 	"net/http"
 )
@@ -22,6 +23,15 @@ func (p GithubPlugin) TestConnection(connection *blink_conn.ConnectionInstance)
 func (p GithubPlugin) GetDefaultRequestUrl() string {
 	return "https://api.github.com"
 }
+
+func (p GithubPlugin) GetPagination() types.Pagination {
+	return types.Pagination{
+		Strategy:   types.PaginationLink,
+		LimitParam: "per_page",
+		PageSize:   100,
+	}
+}
+
 func GetNewGithubPlugin() GithubPlugin {
 	return GithubPlugin{}
 }
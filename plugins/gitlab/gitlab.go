@@ -3,6 +3,7 @@ package gitlab
 import (
 	"github.com/blinkops/blink-http/consts"
 	"github.com/blinkops/blink-http/plugins/connections"
+	"github.com/blinkops/blink-http/plugins/types"
 	blink_conn "github.com/blinkops/blink-sdk/plugin/connections"
 	"net/http"
 )
@@ -22,6 +23,15 @@ func (p GitlabPlugin) TestConnection(connection *blink_conn.ConnectionInstance)
 func (p GitlabPlugin) GetDefaultRequestUrl() string {
 	return "https://gitlab.com/api/v4"
 }
+
+func (p GitlabPlugin) GetPagination() types.Pagination {
+	return types.Pagination{
+		Strategy:   types.PaginationLink,
+		LimitParam: "per_page",
+		PageSize:   100,
+	}
+}
+
 func GetNewGitlabPlugin() GitlabPlugin {
 	return GitlabPlugin{}
 }
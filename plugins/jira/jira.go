@@ -3,6 +3,7 @@ package jira
 import (
 	"github.com/blinkops/blink-http/consts"
 	"github.com/blinkops/blink-http/plugins/connections"
+	"github.com/blinkops/blink-http/plugins/types"
 	blink_conn "github.com/blinkops/blink-sdk/plugin/connections"
 	"net/http"
 )
@@ -23,6 +24,15 @@ func (p JiraPlugin) GetDefaultRequestUrl() string {
 	return ".atlassian.net"
 }
 
+func (p JiraPlugin) GetPagination() types.Pagination {
+	return types.Pagination{
+		Strategy:    types.PaginationOffset,
+		OffsetParam: "startAt",
+		LimitParam:  "maxResults",
+		PageSize:    50,
+	}
+}
+
 func GetNewJiraPlugin() JiraPlugin {
 	return JiraPlugin{}
 }
@@ -0,0 +1,114 @@
+package oauth2
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/blinkops/blink-http/consts"
+	"github.com/blinkops/blink-http/implementation/transport"
+	"github.com/blinkops/blink-http/plugins/tokens"
+	blink_conn "github.com/blinkops/blink-sdk/plugin/connections"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	tokenUrlKey     = "TOKEN_URL"
+	clientIdKey     = "CLIENT_ID"
+	clientSecretKey = "CLIENT_SECRET"
+	scopesKey       = "SCOPES"
+	audienceKey     = "AUDIENCE"
+	authStyleKey    = "AUTH_STYLE"
+
+	authStyleHeader = "header"
+	authStyleBody   = "body"
+)
+
+type OAuth2Plugin struct{}
+
+func (p OAuth2Plugin) HandleAuth(req *http.Request, conn map[string]string) error {
+	token, err := tokens.GetToken("oauth2-client-credentials", conn, func() (*tokens.Token, error) {
+		return fetchToken(conn)
+	})
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", token.Authorization())
+	return nil
+}
+
+func (p OAuth2Plugin) TestConnection(connection *blink_conn.ConnectionInstance) (bool, []byte) {
+	if _, err := fetchToken(connection.Data); err != nil {
+		return false, []byte("Test connection failed. " + err.Error())
+	}
+	return true, nil
+}
+
+func (p OAuth2Plugin) GetDefaultRequestUrl() string {
+	return ""
+}
+
+func fetchToken(conn map[string]string) (*tokens.Token, error) {
+	tokenUrl, clientId, clientSecret := conn[tokenUrlKey], conn[clientIdKey], conn[clientSecretKey]
+	if tokenUrl == "" || clientId == "" || clientSecret == "" {
+		return nil, fmt.Errorf("oauth2-client-credentials connection requires %s, %s and %s", tokenUrlKey, clientIdKey, clientSecretKey)
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if scopes := strings.Join(strings.FieldsFunc(conn[scopesKey], isScopeSeparator), " "); scopes != "" {
+		form.Set("scope", scopes)
+	}
+	if audience := conn[audienceKey]; audience != "" {
+		form.Set("audience", audience)
+	}
+
+	authStyle := strings.ToLower(conn[authStyleKey])
+	switch authStyle {
+	case "", authStyleHeader:
+	case authStyleBody:
+		form.Set("client_id", clientId)
+		form.Set("client_secret", clientSecret)
+	default:
+		return nil, fmt.Errorf("invalid %s: %s, must be %s or %s", authStyleKey, conn[authStyleKey], authStyleHeader, authStyleBody)
+	}
+
+	tokenRequest, err := http.NewRequest(http.MethodPost, tokenUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", tokenUrlKey, err)
+	}
+	tokenRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	tokenRequest.Header.Set("Accept", "application/json")
+	if authStyle != authStyleBody {
+		// RFC 6749 section 2.3.1, the client credentials are form encoded before being base64 encoded
+		credentials := url.QueryEscape(clientId) + ":" + url.QueryEscape(clientSecret)
+		tokenRequest.Header.Set("Authorization", consts.BasicAuthPrefix+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	}
+
+	client, err := transport.NewClient(transport.GetTokenTransportSettings(conn), time.Second*time.Duration(consts.DefaultTimeout))
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := client.Do(tokenRequest)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request an access token from %s, error: %v", tokenUrl, err)
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the access token response, error: %v", err)
+	}
+
+	return tokens.ParseTokenResponse(res.StatusCode, body)
+}
+
+func isScopeSeparator(r rune) bool {
+	return r == ',' || r == ' ' || r == '\n'
+}
+
+func GetNewOAuth2Plugin() OAuth2Plugin {
+	return OAuth2Plugin{}
+}
@@ -0,0 +1,79 @@
+package oauth2
+
+import (
+	blink_conn "github.com/blinkops/blink-sdk/plugin/connections"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type OAuth2TestSuite struct {
+	suite.Suite
+}
+
+func TestOAuth2TestSuite(t *testing.T) {
+	suite.Run(t, new(OAuth2TestSuite))
+}
+
+func (suite *OAuth2TestSuite) TestHandleAuth() {
+	tokenRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		suite.Nil(r.ParseForm())
+		suite.Equal("client_credentials", r.PostForm.Get("grant_type"))
+		suite.Equal("read write", r.PostForm.Get("scope"))
+		suite.Equal("https://api.example.com", r.PostForm.Get("audience"))
+
+		clientId, clientSecret, ok := r.BasicAuth()
+		if !ok {
+			clientId, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+		}
+		if clientId != "client" || clientSecret != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error": "invalid_client", "error_description": "bad client credentials"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token": "token-` + r.PostForm.Get("client_id") + `", "token_type": "bearer", "expires_in": 3600}`))
+	}))
+	defer server.Close()
+
+	conn := map[string]string{
+		tokenUrlKey:     server.URL,
+		clientIdKey:     "client",
+		clientSecretKey: "secret",
+		scopesKey:       "read,write",
+		audienceKey:     "https://api.example.com",
+	}
+	plugin := GetNewOAuth2Plugin()
+
+	for i := 0; i < 2; i++ {
+		request, err := http.NewRequest(http.MethodGet, "https://api.example.com", nil)
+		suite.Require().Nil(err)
+		suite.Nil(plugin.HandleAuth(request, conn))
+		suite.Equal("Bearer token-", request.Header.Get("Authorization"))
+	}
+	suite.Equal(1, tokenRequests, "the token should be cached")
+
+	conn[authStyleKey] = authStyleBody
+	request, err := http.NewRequest(http.MethodGet, "https://api.example.com", nil)
+	suite.Require().Nil(err)
+	suite.Nil(plugin.HandleAuth(request, conn))
+	suite.Equal("Bearer token-client", request.Header.Get("Authorization"))
+	suite.Equal(2, tokenRequests)
+
+	conn[clientSecretKey] = "wrong"
+	err = plugin.HandleAuth(request, conn)
+	suite.NotNil(err)
+	suite.Contains(err.Error(), "invalid_client: bad client credentials")
+
+	valid, _ := plugin.TestConnection(&blink_conn.ConnectionInstance{Data: conn})
+	suite.False(valid)
+
+	conn[authStyleKey] = "query"
+	suite.NotNil(plugin.HandleAuth(request, conn))
+
+	valid, _ = plugin.TestConnection(&blink_conn.ConnectionInstance{Data: map[string]string{tokenUrlKey: server.URL}})
+	suite.False(valid)
+}
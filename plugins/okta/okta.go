@@ -2,6 +2,7 @@ package okta
 
 import (
 	"github.com/blinkops/blink-http/plugins/connections"
+	"github.com/blinkops/blink-http/plugins/types"
 	blink_conn "github.com/blinkops/blink-sdk/plugin/connections"
 	"net/http"
 )
@@ -20,6 +21,14 @@ func (p OktaPlugin) GetDefaultRequestUrl() string {
 	return ".okta.com"
 }
 
+func (p OktaPlugin) GetPagination() types.Pagination {
+	return types.Pagination{
+		Strategy:   types.PaginationLink,
+		LimitParam: "limit",
+		PageSize:   200,
+	}
+}
+
 func GetNewOktaPlugin() OktaPlugin {
 	return OktaPlugin{}
 }
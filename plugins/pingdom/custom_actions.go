@@ -33,6 +33,6 @@ func CreateWebUptimeCheck(ctx *plugin.ActionContext, request *plugin.ExecuteActi
 		"type": {"http"},
 	}
 
-	headers := map[string]string{"Content-Type": "application/x-www-form-urlencoded"}
-	return requests.SendRequest(ctx, plugin, http.MethodPost, "https://api.pingdom.com/api/3.1/checks", request.Timeout, nil, headers, []byte(form.Encode()))
+	headers := http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+	return requests.SendRequest(ctx, plugin, http.MethodPost, "https://api.pingdom.com/api/3.1/checks", request.Timeout, headers, nil, []byte(form.Encode()))
 }
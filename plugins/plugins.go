@@ -1,16 +1,19 @@
 package plugins
 
 import (
+	"github.com/blinkops/blink-http/plugins/aws"
 	"github.com/blinkops/blink-http/plugins/azure"
 	"github.com/blinkops/blink-http/plugins/azure-devops"
 	"github.com/blinkops/blink-http/plugins/bitbucket"
 	"github.com/blinkops/blink-http/plugins/datadog"
+	"github.com/blinkops/blink-http/plugins/digest"
 	"github.com/blinkops/blink-http/plugins/elasticsearch"
 	"github.com/blinkops/blink-http/plugins/gcp"
 	"github.com/blinkops/blink-http/plugins/github"
 	"github.com/blinkops/blink-http/plugins/gitlab"
 	"github.com/blinkops/blink-http/plugins/grafana"
 	"github.com/blinkops/blink-http/plugins/jira"
+	"github.com/blinkops/blink-http/plugins/oauth2"
 	"github.com/blinkops/blink-http/plugins/okta"
 	"github.com/blinkops/blink-http/plugins/opsgenie"
 	"github.com/blinkops/blink-http/plugins/pagerduty"
@@ -23,22 +26,25 @@ import (
 )
 
 var Plugins = map[string]types.Plugin{
-	"azure":         azure.GetNewAzurePlugin(),
-	"azure-devops":  azure_devops.GetNewAzureDevopsPlugin(),
-	"bitbucket":     bitbucket.GetNewBitbucketPlugin(),
-	"datadog":       datadog.GetNewDatadogPlugin(),
-	"elasticsearch": elasticsearch.GetNewElasticSearchPlugin(),
-	"gcp":           gcp.GetNewGcpPlugin(),
-	"github":        github.GetNewGithubPlugin(),
-	"gitlab":        gitlab.GetNewGitlabPlugin(),
-	"grafana":       grafana.GetNewGrafanaPlugin(),
-	"jira":          jira.GetNewJiraPlugin(),
-	"okta":          okta.GetNewOktaPlugin(),
-	"opsgenie":      opsgenie.GetNewOpsgeniePlugin(),
-	"pagerduty":     pagerduty.GetNewPagerdutyPlugin(),
-	"pingdom":       pingdom.GetNewPingdomPlugin(),
-	"prometheus":    prometheus.GetNewPrometheusPlugin(),
-	"slack":         slack.GetNewSlackPlugin(),
-	"virus-total":   virus_total.GetNewVirusTotalPlugin(),
-	"wiz":           wiz.GetNewWizPlugin(),
+	"aws":                       aws.GetNewAwsPlugin(),
+	"azure":                     azure.GetNewAzurePlugin(),
+	"azure-devops":              azure_devops.GetNewAzureDevopsPlugin(),
+	"bitbucket":                 bitbucket.GetNewBitbucketPlugin(),
+	"datadog":                   datadog.GetNewDatadogPlugin(),
+	"digest-auth":               digest.GetNewDigestPlugin(),
+	"elasticsearch":             elasticsearch.GetNewElasticSearchPlugin(),
+	"gcp":                       gcp.GetNewGcpPlugin(),
+	"github":                    github.GetNewGithubPlugin(),
+	"gitlab":                    gitlab.GetNewGitlabPlugin(),
+	"grafana":                   grafana.GetNewGrafanaPlugin(),
+	"jira":                      jira.GetNewJiraPlugin(),
+	"oauth2-client-credentials": oauth2.GetNewOAuth2Plugin(),
+	"okta":                      okta.GetNewOktaPlugin(),
+	"opsgenie":                  opsgenie.GetNewOpsgeniePlugin(),
+	"pagerduty":                 pagerduty.GetNewPagerdutyPlugin(),
+	"pingdom":                   pingdom.GetNewPingdomPlugin(),
+	"prometheus":                prometheus.GetNewPrometheusPlugin(),
+	"slack":                     slack.GetNewSlackPlugin(),
+	"virus-total":               virus_total.GetNewVirusTotalPlugin(),
+	"wiz":                       wiz.GetNewWizPlugin(),
 }
@@ -3,6 +3,7 @@ package slack
 import (
 	"github.com/blinkops/blink-http/consts"
 	"github.com/blinkops/blink-http/plugins/connections"
+	"github.com/blinkops/blink-http/plugins/types"
 	blink_conn "github.com/blinkops/blink-sdk/plugin/connections"
 	"net/http"
 )
@@ -22,6 +23,17 @@ func (p SlackPlugin) TestConnection(connection *blink_conn.ConnectionInstance) (
 func (p SlackPlugin) GetDefaultRequestUrl() string {
 	return "https://slack.com/api"
 }
+
+func (p SlackPlugin) GetPagination() types.Pagination {
+	return types.Pagination{
+		Strategy:    types.PaginationCursor,
+		CursorPath:  "response_metadata.next_cursor",
+		CursorParam: "cursor",
+		LimitParam:  "limit",
+		PageSize:    200,
+	}
+}
+
 func GetNewSlackPlugin() SlackPlugin {
 	return SlackPlugin{}
 }
@@ -0,0 +1,192 @@
+package tokens
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// tokens closer than this to their expiry are never used, so they don't expire in flight
+	expiryMargin = 30 * time.Second
+	// tokens closer than this to their expiry are still used, but refreshed in the background
+	refreshAhead = 5 * time.Minute
+)
+
+type Token struct {
+	AccessToken string
+	TokenType   string
+	Expiry      time.Time
+	// Extra holds other values issued along with the token, like the key pair of temporary AWS credentials
+	Extra map[string]string
+}
+
+// Authorization returns the value of the Authorization header
+func (t *Token) Authorization() string {
+	return t.TokenType + " " + t.AccessToken
+}
+
+// Cache keeps access tokens in memory until shortly before they expire.
+// concurrent requests for the same missing token share a single fetch.
+type Cache struct {
+	lock   sync.Mutex
+	tokens map[string]*Token
+	group  singleflight.Group
+	now    func() time.Time
+}
+
+var defaultCache = NewCache()
+
+func NewCache() *Cache {
+	return &Cache{tokens: map[string]*Token{}, now: time.Now}
+}
+
+// GetToken returns the cached token of the connection, fetching it when there is none or it's about to expire.
+// the namespace separates the integrations, so identical credentials of different integrations don't share a token
+func GetToken(namespace string, conn map[string]string, fetch func() (*Token, error)) (*Token, error) {
+	return defaultCache.Get(GetCacheKey(namespace, conn), fetch)
+}
+
+// ClearTokens empties the shared cache, so every connection fetches a new token on its next request
+func ClearTokens() {
+	defaultCache.Clear()
+}
+
+func (c *Cache) Clear() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.tokens = map[string]*Token{}
+}
+
+func (c *Cache) Get(key string, fetch func() (*Token, error)) (*Token, error) {
+	c.lock.Lock()
+	cached, ok := c.tokens[key]
+	c.lock.Unlock()
+
+	now := c.now()
+	if ok && now.Add(expiryMargin).Before(cached.Expiry) {
+		if !now.Add(refreshAhead).Before(cached.Expiry) {
+			expiry := cached.Expiry
+			c.group.DoChan(key, func() (interface{}, error) {
+				// the failure is logged here, since the cached token is returned until it expires, hiding the error until then
+				token, err := c.fetch(key, fetch)
+				if err != nil {
+					log.Warnf("failed to refresh the token that expires at %s, error: %v", expiry.Format(time.RFC3339), err)
+				}
+				return token, err
+			})
+		}
+		return cached, nil
+	}
+
+	token, err, _ := c.group.Do(key, func() (interface{}, error) {
+		return c.fetch(key, fetch)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return token.(*Token), nil
+}
+
+func (c *Cache) fetch(key string, fetch func() (*Token, error)) (*Token, error) {
+	token, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	// tokens without an expiry are used once, there is no way to know when they stop working
+	if token.Expiry.IsZero() {
+		delete(c.tokens, key)
+	} else {
+		c.tokens[key] = token
+		time.AfterFunc(token.Expiry.Sub(c.now()), func() {
+			c.evict(key, token)
+		})
+	}
+	return token, nil
+}
+
+// evict deletes the expired token, so the tokens of connections that are no longer used don't stay in memory.
+// a token that was refreshed in the meantime is kept
+func (c *Cache) evict(key string, token *Token) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.tokens[key] == token {
+		delete(c.tokens, key)
+	}
+}
+
+// GetCacheKey hashes all the connection attributes, so a changed secret never gets a token of the old one
+func GetCacheKey(namespace string, conn map[string]string) string {
+	keys := make([]string, 0, len(conn))
+	for key := range conn {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	hash.Write([]byte(namespace))
+	for _, key := range keys {
+		hash.Write([]byte{0})
+		hash.Write([]byte(key))
+		hash.Write([]byte{0})
+		hash.Write([]byte(conn[key]))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// ParseTokenResponse parses an OAuth2 token response (RFC 6749 section 5), including its error response
+func ParseTokenResponse(statusCode int, body []byte) (*Token, error) {
+	var responseBody struct {
+		AccessToken      string      `json:"access_token"`
+		TokenType        string      `json:"token_type"`
+		ExpiresIn        interface{} `json:"expires_in"`
+		Error            string      `json:"error"`
+		ErrorDescription string      `json:"error_description"`
+	}
+	_ = json.Unmarshal(body, &responseBody)
+
+	if responseBody.Error != "" {
+		if responseBody.ErrorDescription != "" {
+			return nil, fmt.Errorf("the token endpoint returned %s: %s (status %d)", responseBody.Error, responseBody.ErrorDescription, statusCode)
+		}
+		return nil, fmt.Errorf("the token endpoint returned %s (status %d)", responseBody.Error, statusCode)
+	}
+	if statusCode < http.StatusOK || statusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("the token endpoint returned status %d: %s", statusCode, string(body))
+	}
+	if responseBody.AccessToken == "" {
+		return nil, errors.New("the token endpoint response does not contain an access_token")
+	}
+
+	token := &Token{AccessToken: responseBody.AccessToken, TokenType: "Bearer"}
+	if responseBody.TokenType != "" && !strings.EqualFold(responseBody.TokenType, "bearer") {
+		token.TokenType = responseBody.TokenType
+	}
+
+	// some identity providers, like azure, send expires_in as a string
+	var expiresIn float64
+	switch value := responseBody.ExpiresIn.(type) {
+	case float64:
+		expiresIn = value
+	case string:
+		expiresIn, _ = strconv.ParseFloat(value, 64)
+	}
+	if expiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	}
+	return token, nil
+}
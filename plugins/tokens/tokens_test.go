@@ -0,0 +1,156 @@
+package tokens
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/suite"
+)
+
+type TokensTestSuite struct {
+	suite.Suite
+}
+
+func TestTokensTestSuite(t *testing.T) {
+	suite.Run(t, new(TokensTestSuite))
+}
+
+func (suite *TokensTestSuite) TestGet() {
+	cache := NewCache()
+	clock := sync.Mutex{}
+	now := time.Now()
+	cache.now = func() time.Time {
+		clock.Lock()
+		defer clock.Unlock()
+		return now
+	}
+	advance := func(duration time.Duration) {
+		clock.Lock()
+		defer clock.Unlock()
+		now = now.Add(duration)
+	}
+
+	var fetches int32
+	fetch := func() (*Token, error) {
+		count := atomic.AddInt32(&fetches, 1)
+		time.Sleep(10 * time.Millisecond)
+		return &Token{AccessToken: string(rune('a' + count - 1)), Expiry: cache.now().Add(time.Hour)}, nil
+	}
+
+	// concurrent requests for a missing token share a single fetch
+	wait := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			token, err := cache.Get("key", fetch)
+			suite.Nil(err)
+			suite.Equal("a", token.AccessToken)
+		}()
+	}
+	wait.Wait()
+	suite.Equal(int32(1), atomic.LoadInt32(&fetches))
+
+	// close to the expiry the cached token is still returned while a new one is fetched in the background
+	advance(time.Hour - refreshAhead + time.Second)
+	token, err := cache.Get("key", fetch)
+	suite.Nil(err)
+	suite.Equal("a", token.AccessToken)
+	suite.Eventually(func() bool {
+		cache.lock.Lock()
+		defer cache.lock.Unlock()
+		return cache.tokens["key"].AccessToken == "b"
+	}, time.Second, time.Millisecond)
+
+	// an expired token is never returned
+	advance(2 * time.Hour)
+	token, err = cache.Get("key", fetch)
+	suite.Nil(err)
+	suite.Equal("c", token.AccessToken)
+
+	_, err = cache.Get("other", func() (*Token, error) { return nil, errors.New("invalid_client") })
+	suite.NotNil(err)
+}
+
+func (suite *TokensTestSuite) TestRefreshAheadFailure() {
+	hook := test.NewGlobal()
+	defer log.StandardLogger().ReplaceHooks(log.LevelHooks{})
+
+	cache := NewCache()
+	token := &Token{AccessToken: "a", Expiry: time.Now().Add(time.Minute)}
+	cache.tokens["key"] = token
+
+	// the failed refresh is logged, while the cached token is still returned until it expires
+	cached, err := cache.Get("key", func() (*Token, error) { return nil, errors.New("invalid_client") })
+	suite.Nil(err)
+	suite.True(cached == token)
+	suite.Eventually(func() bool {
+		entry := hook.LastEntry()
+		return entry != nil && entry.Level == log.WarnLevel && strings.Contains(entry.Message, "invalid_client")
+	}, time.Second, time.Millisecond)
+	suite.Len(hook.AllEntries(), 1)
+}
+
+func (suite *TokensTestSuite) TestEvictExpiredTokens() {
+	cache := NewCache()
+	fetch := func() (*Token, error) {
+		return &Token{AccessToken: "short", Expiry: time.Now().Add(50 * time.Millisecond)}, nil
+	}
+
+	_, err := cache.Get("abandoned", fetch)
+	suite.Nil(err)
+	refreshed, err := cache.Get("refreshed", fetch)
+	suite.Nil(err)
+
+	// a newer token of the key isn't deleted by the timer of the token it replaced
+	cache.lock.Lock()
+	cache.tokens["refreshed"] = &Token{AccessToken: "long", Expiry: time.Now().Add(time.Hour)}
+	cache.lock.Unlock()
+	suite.NotEqual("long", refreshed.AccessToken)
+
+	suite.Eventually(func() bool {
+		cache.lock.Lock()
+		defer cache.lock.Unlock()
+		_, ok := cache.tokens["abandoned"]
+		return !ok
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	suite.Equal("long", cache.tokens["refreshed"].AccessToken)
+}
+
+func (suite *TokensTestSuite) TestGetCacheKey() {
+	conn := map[string]string{"Client ID": "id", "Client Secret": "secret"}
+	suite.Equal(GetCacheKey("wiz", conn), GetCacheKey("wiz", map[string]string{"Client Secret": "secret", "Client ID": "id"}))
+	suite.NotEqual(GetCacheKey("wiz", conn), GetCacheKey("azure", conn))
+	suite.NotEqual(GetCacheKey("wiz", conn), GetCacheKey("wiz", map[string]string{"Client ID": "id", "Client Secret": "changed"}))
+}
+
+func (suite *TokensTestSuite) TestParseTokenResponse() {
+	token, err := ParseTokenResponse(http.StatusOK, []byte(`{"access_token": "abc", "token_type": "Bearer", "expires_in": "3599"}`))
+	suite.Nil(err)
+	suite.Equal("Bearer abc", token.Authorization())
+	suite.WithinDuration(time.Now().Add(3599*time.Second), token.Expiry, time.Minute)
+
+	token, err = ParseTokenResponse(http.StatusOK, []byte(`{"access_token": "abc", "token_type": "MAC"}`))
+	suite.Nil(err)
+	suite.Equal("MAC abc", token.Authorization())
+	suite.True(token.Expiry.IsZero())
+
+	_, err = ParseTokenResponse(http.StatusBadRequest, []byte(`{"error": "invalid_scope", "error_description": "unknown scope"}`))
+	suite.EqualError(err, "the token endpoint returned invalid_scope: unknown scope (status 400)")
+
+	_, err = ParseTokenResponse(http.StatusBadGateway, []byte(`bad gateway`))
+	suite.NotNil(err)
+
+	_, err = ParseTokenResponse(http.StatusOK, []byte(`{}`))
+	suite.NotNil(err)
+}
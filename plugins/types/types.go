@@ -15,6 +15,13 @@ type Plugin interface {
 	GetDefaultRequestUrl() string
 }
 
+// PluginWithDefaultRequestUrls sends requests to several domains, requests without a request url in the connection
+// are allowed to any of them rather than to GetDefaultRequestUrl only
+type PluginWithDefaultRequestUrls interface {
+	Plugin
+	GetDefaultRequestUrls() []string
+}
+
 type CustomPlugin interface {
 	Plugin
 	GetCustomActionHandlers() map[string]ActionHandler
@@ -25,3 +32,36 @@ type PluginWithValidation interface {
 	Plugin
 	ValidateResponse(statusCode int, body []byte) ([]byte, error)
 }
+
+// PluginWithChallenge authenticates in response to a 401 challenge, such as digest auth.
+// HandleChallenge reports whether the request should be resent with the new credentials.
+type PluginWithChallenge interface {
+	Plugin
+	HandleChallenge(response *http.Response, conn map[string]string) (bool, error)
+}
+
+const (
+	PaginationLink   = "link"
+	PaginationCursor = "cursor"
+	PaginationOffset = "offset"
+	PaginationPage   = "page"
+)
+
+// Pagination describes how to fetch the next page of a list response.
+// ItemsPath is a dot separated path to the items array, when empty the response
+// itself or its only array field is used.
+type Pagination struct {
+	Strategy    string
+	ItemsPath   string
+	CursorPath  string
+	CursorParam string
+	OffsetParam string
+	PageParam   string
+	LimitParam  string
+	PageSize    int
+}
+
+type PluginWithPagination interface {
+	Plugin
+	GetPagination() Pagination
+}
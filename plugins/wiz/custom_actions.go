@@ -9,11 +9,16 @@ import (
 )
 
 const (
-	projectNameParam = "ProjectName"
-	limitParam       = "Limit"
-	offsetParam      = "Offset"
-	filterByParam    = "FilterBy"
-	orderByParam     = "OrderBy"
+	projectNameParam   = "ProjectName"
+	limitParam         = "Limit"
+	offsetParam        = "Offset"
+	filterByParam      = "FilterBy"
+	orderByParam       = "OrderBy"
+	fetchAllPagesParam = "FetchAllPages"
+	maxItemsParam      = "MaxItems"
+
+	defaultMaxItems = 1000
+	maxPages        = 100
 )
 
 type listCloudConfigurationRulesVariables struct {
@@ -94,7 +99,12 @@ func listCloudConfigurationRules(ctx *plugin.ActionContext, request *plugin.Exec
 		return nil, errors.New("failed to marshal variables")
 	}
 
-	return execQuery(ctx, request, plugin, listCloudConfigurationRulesQuery, variables)
+	pagination, err := getPagination(params, "data.cloudConfigurationRules")
+	if err != nil {
+		return nil, err
+	}
+
+	return execQuery(ctx, request, plugin, listCloudConfigurationRulesQuery, variables, pagination)
 }
 
 func listControls(ctx *plugin.ActionContext, request *plugin.ExecuteActionRequest, plugin types.Plugin) ([]byte, error) {
@@ -142,5 +152,10 @@ func listControls(ctx *plugin.ActionContext, request *plugin.ExecuteActionReques
 		return nil, errors.New("failed to marshal variables")
 	}
 
-	return execQuery(ctx, request, plugin, listControlsQuery, variables)
+	pagination, err := getPagination(params, "data.controls")
+	if err != nil {
+		return nil, err
+	}
+
+	return execQuery(ctx, request, plugin, listControlsQuery, variables, pagination)
 }
@@ -7,23 +7,25 @@ import (
 	"github.com/blinkops/blink-http/implementation/requests"
 	"github.com/blinkops/blink-http/plugins/types"
 	"github.com/blinkops/blink-sdk/plugin"
-	"net/http"
+	"strconv"
 )
 
-func execQuery(ctx *plugin.ActionContext, request *plugin.ExecuteActionRequest, plugin types.Plugin, query string, variables []byte) ([]byte, error) {
+// execQuery returns the query response, or the nodes of all the pages when pagination is given
+func execQuery(ctx *plugin.ActionContext, request *plugin.ExecuteActionRequest, plugin types.Plugin, query string, variables []byte, pagination *requests.GraphQLPagination) ([]byte, error) {
 	requestUrl, err := getRequestUrl(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	body, err := json.Marshal(map[string]string{"query": query, "variables": string(variables)})
+	graphQLRequest, err := requests.NewGraphQLRequest(query, string(variables), "")
 	if err != nil {
 		return nil, err
 	}
 
-	headerMap := map[string]string{"Content-Type": "application/json"}
-
-	return requests.SendRequest(ctx, plugin, http.MethodPost, requestUrl, request.Timeout, headerMap, nil, body)
+	if pagination != nil {
+		return requests.SendPaginatedGraphQLRequest(ctx, plugin, requestUrl, request.Timeout, nil, graphQLRequest, nil, pagination)
+	}
+	return requests.SendGraphQLRequest(ctx, plugin, requestUrl, request.Timeout, nil, graphQLRequest, nil)
 }
 
 func getRequestUrl(ctx *plugin.ActionContext) (string, error) {
@@ -41,9 +43,12 @@ func getRequestUrl(ctx *plugin.ActionContext) (string, error) {
 }
 
 func getProjectIdByName(ctx *plugin.ActionContext, request *plugin.ExecuteActionRequest, plugin types.Plugin, projectName string) (string, error) {
-	variables := `{"first":1, "search":"`+ projectName +`"}`
+	variables, err := json.Marshal(map[string]interface{}{"first": 1, "search": projectName})
+	if err != nil {
+		return "", errors.New("failed to marshal variables")
+	}
 
-	resp, err := execQuery(ctx, request, plugin, listProjectsQuery, []byte(variables))
+	resp, err := execQuery(ctx, request, plugin, listProjectsQuery, variables, nil)
 	if err != nil {
 		return "", err
 	}
@@ -60,3 +65,31 @@ func getProjectIdByName(ctx *plugin.ActionContext, request *plugin.ExecuteAction
 
 	return respJson.Data.Projects.Nodes[0].Id, nil
 }
+
+// getPagination returns the pagination of the connection when all the pages are requested, the page size is the limit
+func getPagination(params map[string]string, connectionPath string) (*requests.GraphQLPagination, error) {
+	fetchAllPages := params[fetchAllPagesParam]
+	if fetchAllPages == "" {
+		return nil, nil
+	}
+	fetchAll, err := strconv.ParseBool(fetchAllPages)
+	if err != nil {
+		return nil, errors.New("fetch all pages must be a boolean")
+	}
+	if !fetchAll {
+		return nil, nil
+	}
+
+	pagination := &requests.GraphQLPagination{
+		ConnectionPath: connectionPath,
+		CursorVariable: "after",
+		MaxPages:       maxPages,
+		MaxItems:       defaultMaxItems,
+	}
+	if maxItems := params[maxItemsParam]; maxItems != "" {
+		if pagination.MaxItems, err = strconv.Atoi(maxItems); err != nil || pagination.MaxItems < 1 {
+			return nil, errors.New("max items must be a positive integer")
+		}
+	}
+	return pagination, nil
+}
@@ -1,19 +1,34 @@
 package wiz
 
 import (
-	"encoding/json"
 	"errors"
+	"github.com/blinkops/blink-http/consts"
+	"github.com/blinkops/blink-http/implementation/transport"
+	"github.com/blinkops/blink-http/plugins/tokens"
 	"github.com/blinkops/blink-http/plugins/types"
 	blink_conn "github.com/blinkops/blink-sdk/plugin/connections"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 )
 
 type WizPlugin struct{}
 
 func (p WizPlugin) HandleAuth(req *http.Request, conn map[string]string) error {
+	token, err := tokens.GetToken("wiz", conn, func() (*tokens.Token, error) {
+		return getAccessToken(conn)
+	})
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("AUTHORIZATION", "Bearer "+token.AccessToken)
+	return nil
+}
+
+func getAccessToken(conn map[string]string) (*tokens.Token, error) {
 	queryParams := url.Values{
 		"grant_type":    {"client_credentials"},
 		"client_id":     {conn["Client ID"]},
@@ -21,49 +36,41 @@ func (p WizPlugin) HandleAuth(req *http.Request, conn map[string]string) error {
 		"audience":      {"beyond-api"},
 	}
 
-	client := &http.Client{}
+	client, err := transport.NewClient(transport.GetTokenTransportSettings(conn), time.Second*time.Duration(consts.DefaultTimeout))
+	if err != nil {
+		return nil, err
+	}
 
 	request, err := http.NewRequest(http.MethodPost, "https://auth.wiz.io/oauth/token", strings.NewReader(queryParams.Encode()))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
 	res, err := client.Do(request)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	defer func() { _ = res.Body.Close() }()
 
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	var responseBody struct {
-		AccessToken string `json:"access_token"`
-	}
-
-	err = json.Unmarshal(body, &responseBody)
+	token, err := tokens.ParseTokenResponse(res.StatusCode, body)
 	if err != nil {
-		return err
+		return nil, errors.New("invalid credentials, " + err.Error())
 	}
-
-	if responseBody.AccessToken == "" {
-		return errors.New("invalid credentials")
-	}
-
-	req.Header.Set("AUTHORIZATION", "Bearer "+responseBody.AccessToken)
-	return nil
+	return token, nil
 }
 
 func (p WizPlugin) TestConnection(connection *blink_conn.ConnectionInstance) (bool, []byte) {
 
-	req, _ := http.NewRequest(http.MethodGet, "https://api.wiz.com/auth.test", nil)
-
-	err := p.HandleAuth(req, connection.Data)
+	// the token is fetched rather than taken from the cache, so revoked or edited credentials fail the test
+	_, err := getAccessToken(connection.Data)
 
 	if err != nil {
 		return false, []byte(err.Error())